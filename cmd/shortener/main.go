@@ -6,17 +6,43 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/jmoiron/sqlx"
+	"github.com/virp/go-shortener/internal/app/geoip"
 	"github.com/virp/go-shortener/internal/app/handlers"
+	"github.com/virp/go-shortener/internal/app/linkcheck"
+	"github.com/virp/go-shortener/internal/app/metadata"
+	"github.com/virp/go-shortener/internal/app/qr"
+	"github.com/virp/go-shortener/internal/app/ratelimit"
+	"github.com/virp/go-shortener/internal/app/safehttp"
 	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/urlnorm"
+	"github.com/virp/go-shortener/internal/app/urlpolicy"
+	"github.com/virp/go-shortener/internal/app/webhooks"
 )
 
-const defaultDatabaseQueryTimeout = "3s"
+const (
+	defaultDatabaseQueryTimeout = "3s"
+	purgeDeletedInterval        = time.Hour
+	geoIPReloadInterval         = time.Minute
+	rollupInterval              = time.Minute
+	qrCacheSize                 = 1024
+	metadataTimeout             = 5 * time.Second
+	metadataMaxBodySize         = 512 << 10
+	metadataQueueSize           = 1000
+	linkCheckTimeout            = 10 * time.Second
+	webhookTimeout              = 10 * time.Second
+	webhookDeliveryInterval     = 5 * time.Second
+	migrationTimeout            = 10 * time.Minute
+)
 
 type config struct {
 	serverAddress        string
@@ -24,6 +50,29 @@ type config struct {
 	fileStoragePath      string
 	databaseDSN          string
 	databaseQueryTimeout time.Duration
+	createRateLimit      string
+	redirectRateLimit    string
+	passwordRateLimit    string
+	rateLimitShared      bool
+	anonymousQuota       string
+	userQuota            string
+	allowedSchemes       string
+	allowedDomains       string
+	deniedDomains        string
+	blocklistFile        string
+	allowPrivateIPs      bool
+	resolveHosts         bool
+	stripTrackingParams  bool
+	stripTrailingSlash   bool
+	restoreGracePeriod   time.Duration
+	deletedRetention     time.Duration
+	redirectType         int
+	inactiveURL          string
+	countryHeader        string
+	geoIPDatabase        string
+	trustedProxies       string
+	metadataWorkers      int
+	linkCheckInterval    time.Duration
 }
 
 func main() {
@@ -56,20 +105,102 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if cfg.deletedRetention > 0 {
+		go storage.RunRetention(ctx, s, cfg.deletedRetention, purgeDeletedInterval)
+	}
+	go storage.RunRollups(ctx, s, rollupInterval)
+
+	createLimit, err := ratelimit.ParseLimit(cfg.createRateLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	redirectLimit, err := ratelimit.ParseLimit(cfg.redirectRateLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwordLimit, err := ratelimit.ParseLimit(cfg.passwordRateLimit)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	anonymousQuota, err := handlers.ParseQuota(cfg.anonymousQuota)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userQuota, err := handlers.ParseQuota(cfg.userQuota)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	policy, err := getPolicy(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trustedProxies, err := parseNetworks(splitList(cfg.trustedProxies))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	normalization := urlnorm.Options{
+		StripTrackingParams: cfg.stripTrackingParams,
+		StripTrailingSlash:  cfg.stripTrailingSlash,
+	}
+	if cfg.databaseDSN != "" {
+		go backfillCanonicalURLs(ctx, database, normalization)
+	}
+
 	h := handlers.Handlers{
-		Storage: s,
-		BaseURL: cfg.baseURL,
-		Secret:  "secretappkey",
-		DB:      database,
+		Storage:             s,
+		BaseURL:             cfg.baseURL,
+		Secret:              "secretappkey",
+		DB:                  database,
+		Limiter:             getLimiter(cfg, database),
+		CreateLimit:         createLimit,
+		RedirectLimit:       redirectLimit,
+		PasswordLimit:       passwordLimit,
+		AnonymousQuota:      anonymousQuota,
+		UserQuota:           userQuota,
+		Policy:              policy,
+		Normalization:       normalization,
+		RestoreGracePeriod:  cfg.restoreGracePeriod,
+		DefaultRedirectType: cfg.redirectType,
+		InactiveURL:         cfg.inactiveURL,
+		CountryHeader:       cfg.countryHeader,
+		TrustedProxies:      trustedProxies,
+		QRCache:             qr.NewCache(qrCacheSize),
+		Webhooks:            webhooks.NewDispatcher(s, safehttp.NewClient(webhookTimeout, cfg.allowPrivateIPs)),
+	}
+	go h.Webhooks.Run(ctx, webhookDeliveryInterval)
+	if cfg.geoIPDatabase != "" {
+		geoDB, err := geoip.Open(cfg.geoIPDatabase)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go geoDB.Watch(ctx, geoIPReloadInterval)
+		h.GeoIP = geoDB
+	}
+	if cfg.metadataWorkers > 0 {
+		fetcher := metadata.NewFetcher(safehttp.NewClient(metadataTimeout, cfg.allowPrivateIPs), metadataMaxBodySize)
+		h.Metadata = metadata.NewPool(fetcher, func(ctx context.Context, id string, m metadata.Metadata) error {
+			_, err := s.SetMetadata(ctx, id, storage.LinkMetadata(m))
+			return err
+		}, metadataQueueSize)
+		go h.Metadata.Run(ctx, cfg.metadataWorkers)
+	}
+	if cfg.linkCheckInterval > 0 {
+		checker := linkcheck.NewChecker(s, safehttp.NewClient(linkCheckTimeout, cfg.allowPrivateIPs))
+		checker.Webhooks = h.Webhooks
+		go checker.Run(ctx, cfg.linkCheckInterval)
 	}
 	r := handlers.NewRouter(h)
 
 	log.Fatal(http.ListenAndServe(cfg.serverAddress, r))
 }
 
-func getStorage(ctx context.Context, cfg config, db *sqlx.DB) (storage.URLStorage, error) {
+func getStorage(ctx context.Context, cfg config, db *sqlx.DB) (storage.Storage, error) {
 	if cfg.databaseDSN != "" {
-		if err := checkDBTables(db, cfg.databaseQueryTimeout); err != nil {
+		if err := checkDBTables(db, migrationTimeout); err != nil {
 			return nil, fmt.Errorf("check db tables: %w", err)
 		}
 		return storage.NewPostgresStorage(ctx, db, cfg.databaseQueryTimeout)
@@ -80,6 +211,91 @@ func getStorage(ctx context.Context, cfg config, db *sqlx.DB) (storage.URLStorag
 	return storage.NewMemoryStorage()
 }
 
+func backfillCanonicalURLs(ctx context.Context, db *sqlx.DB, opts urlnorm.Options) {
+	n, err := storage.BackfillCanonicalURLs(ctx, db, func(rawURL string) (string, error) {
+		u, err := url.ParseRequestURI(rawURL)
+		if err != nil {
+			return "", err
+		}
+		return urlnorm.Canonicalize(u, opts)
+	})
+	if err != nil {
+		log.Printf("backfill canonical urls: %v", err)
+	} else if n > 0 {
+		log.Printf("backfilled %d canonical urls", n)
+	}
+}
+
+func getLimiter(cfg config, db *sqlx.DB) ratelimit.Limiter {
+	if cfg.rateLimitShared && db != nil {
+		return ratelimit.NewPostgresLimiter(db, cfg.databaseQueryTimeout)
+	}
+	return ratelimit.NewMemoryLimiter()
+}
+
+func getPolicy(cfg config) (*urlpolicy.Policy, error) {
+	base, err := url.Parse(cfg.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base URL: %w", err)
+	}
+
+	policy := &urlpolicy.Policy{
+		AllowedSchemes:  splitList(cfg.allowedSchemes),
+		AllowedDomains:  splitList(cfg.allowedDomains),
+		DeniedDomains:   splitList(cfg.deniedDomains),
+		SelfHosts:       []string{base.Hostname()},
+		AllowPrivateIPs: cfg.allowPrivateIPs,
+	}
+	if cfg.resolveHosts {
+		policy.Resolver = net.DefaultResolver
+	}
+	if cfg.blocklistFile != "" {
+		policy.Blocklist, err = urlpolicy.LoadBlocklist(cfg.blocklistFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return policy, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// parseNetworks accepts both CIDRs and single addresses.
+func parseNetworks(items []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", item, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
 func getConfig() (config, error) {
 	dqt, err := time.ParseDuration(defaultDatabaseQueryTimeout)
 	if err != nil {
@@ -93,6 +309,13 @@ func getConfig() (config, error) {
 		fileStoragePath:      "",
 		databaseDSN:          "",
 		databaseQueryTimeout: dqt,
+		passwordRateLimit:    "5/1m",
+		allowedSchemes:       "http,https",
+		restoreGracePeriod:   24 * time.Hour,
+		deletedRetention:     30 * 24 * time.Hour,
+		redirectType:         http.StatusTemporaryRedirect,
+		metadataWorkers:      4,
+		linkCheckInterval:    24 * time.Hour,
 	}
 
 	// Override config by flags
@@ -108,6 +331,11 @@ func getConfig() (config, error) {
 	if cfg.baseURL == "" {
 		return config{}, errors.New("config: base URL not configured")
 	}
+	switch cfg.redirectType {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return config{}, fmt.Errorf("config: unsupported redirect type %d", cfg.redirectType)
+	}
 
 	return cfg, nil
 }
@@ -117,6 +345,29 @@ func getFlagConfig(cfg config) config {
 	flag.StringVar(&cfg.baseURL, "b", cfg.baseURL, "Base URL")
 	flag.StringVar(&cfg.fileStoragePath, "f", cfg.fileStoragePath, "File Storage Path")
 	flag.StringVar(&cfg.databaseDSN, "d", cfg.databaseDSN, "Database DSN")
+	flag.StringVar(&cfg.createRateLimit, "rate-create", cfg.createRateLimit, "Links creation rate limit, e.g. 60/1m")
+	flag.StringVar(&cfg.redirectRateLimit, "rate-redirect", cfg.redirectRateLimit, "Redirects rate limit, e.g. 600/1m")
+	flag.StringVar(&cfg.passwordRateLimit, "rate-password", cfg.passwordRateLimit, "Protected links password attempts rate limit, e.g. 5/1m")
+	flag.StringVar(&cfg.anonymousQuota, "quota-anonymous", cfg.anonymousQuota, "Anonymous users quota, e.g. total=100,daily=20,batch=10")
+	flag.StringVar(&cfg.userQuota, "quota-user", cfg.userQuota, "Registered users quota, e.g. total=1000,daily=100,batch=100")
+	flag.BoolVar(&cfg.rateLimitShared, "rate-shared", cfg.rateLimitShared, "Share rate limits between instances via database")
+	flag.StringVar(&cfg.allowedSchemes, "url-schemes", cfg.allowedSchemes, "Allowed target URL schemes, comma separated")
+	flag.StringVar(&cfg.allowedDomains, "url-allow-domains", cfg.allowedDomains, "Allowed target domains, comma separated")
+	flag.StringVar(&cfg.deniedDomains, "url-deny-domains", cfg.deniedDomains, "Denied target domains, comma separated")
+	flag.StringVar(&cfg.blocklistFile, "url-blocklist", cfg.blocklistFile, "Blocklist file with known bad hosts")
+	flag.BoolVar(&cfg.allowPrivateIPs, "url-allow-private", cfg.allowPrivateIPs, "Allow targets with private addresses")
+	flag.BoolVar(&cfg.resolveHosts, "url-resolve", cfg.resolveHosts, "Resolve target hosts to reject names of private addresses, without it only IP literals and localhost are rejected")
+	flag.BoolVar(&cfg.stripTrackingParams, "url-strip-tracking", cfg.stripTrackingParams, "Ignore tracking params when detecting duplicates")
+	flag.BoolVar(&cfg.stripTrailingSlash, "url-strip-slash", cfg.stripTrailingSlash, "Ignore trailing slash when detecting duplicates")
+	flag.DurationVar(&cfg.restoreGracePeriod, "restore-grace", cfg.restoreGracePeriod, "Period deleted links may be restored within")
+	flag.DurationVar(&cfg.deletedRetention, "deleted-retention", cfg.deletedRetention, "Period after which deleted links are purged, 0 keeps them forever")
+	flag.IntVar(&cfg.redirectType, "redirect-type", cfg.redirectType, "Default redirect status code: 301, 302, 307 or 308")
+	flag.StringVar(&cfg.inactiveURL, "inactive-url", cfg.inactiveURL, "Placeholder URL for links outside their activation window, 404 if empty")
+	flag.StringVar(&cfg.countryHeader, "country-header", cfg.countryHeader, "Request header with client country code set by trusted proxies, e.g. CF-IPCountry")
+	flag.StringVar(&cfg.geoIPDatabase, "geoip-db", cfg.geoIPDatabase, "GeoIP database file in MaxMind format, reloaded on change")
+	flag.StringVar(&cfg.trustedProxies, "trusted-proxies", cfg.trustedProxies, "Proxies allowed to set X-Forwarded-For and the country header, comma separated IPs or CIDRs")
+	flag.IntVar(&cfg.metadataWorkers, "metadata-workers", cfg.metadataWorkers, "Workers fetching link destination metadata, 0 disables fetching")
+	flag.DurationVar(&cfg.linkCheckInterval, "link-check-interval", cfg.linkCheckInterval, "Period between link destination checks, 0 disables checking")
 	flag.Parse()
 
 	return cfg
@@ -135,29 +386,264 @@ func getEnvConfig(cfg config) config {
 	if dsn, ok := os.LookupEnv("DATABASE_DSN"); ok {
 		cfg.databaseDSN = dsn
 	}
+	if rl, ok := os.LookupEnv("RATE_LIMIT_CREATE"); ok {
+		cfg.createRateLimit = rl
+	}
+	if rl, ok := os.LookupEnv("RATE_LIMIT_REDIRECT"); ok {
+		cfg.redirectRateLimit = rl
+	}
+	if rl, ok := os.LookupEnv("RATE_LIMIT_PASSWORD"); ok {
+		cfg.passwordRateLimit = rl
+	}
+	if rls, ok := os.LookupEnv("RATE_LIMIT_SHARED"); ok {
+		cfg.rateLimitShared, _ = strconv.ParseBool(rls)
+	}
+	if q, ok := os.LookupEnv("QUOTA_ANONYMOUS"); ok {
+		cfg.anonymousQuota = q
+	}
+	if q, ok := os.LookupEnv("QUOTA_USER"); ok {
+		cfg.userQuota = q
+	}
+	if us, ok := os.LookupEnv("URL_ALLOWED_SCHEMES"); ok {
+		cfg.allowedSchemes = us
+	}
+	if ud, ok := os.LookupEnv("URL_ALLOWED_DOMAINS"); ok {
+		cfg.allowedDomains = ud
+	}
+	if ud, ok := os.LookupEnv("URL_DENIED_DOMAINS"); ok {
+		cfg.deniedDomains = ud
+	}
+	if bf, ok := os.LookupEnv("URL_BLOCKLIST_FILE"); ok {
+		cfg.blocklistFile = bf
+	}
+	if ap, ok := os.LookupEnv("URL_ALLOW_PRIVATE"); ok {
+		cfg.allowPrivateIPs, _ = strconv.ParseBool(ap)
+	}
+	if rh, ok := os.LookupEnv("URL_RESOLVE_HOSTS"); ok {
+		cfg.resolveHosts, _ = strconv.ParseBool(rh)
+	}
+	if st, ok := os.LookupEnv("URL_STRIP_TRACKING_PARAMS"); ok {
+		cfg.stripTrackingParams, _ = strconv.ParseBool(st)
+	}
+	if ss, ok := os.LookupEnv("URL_STRIP_TRAILING_SLASH"); ok {
+		cfg.stripTrailingSlash, _ = strconv.ParseBool(ss)
+	}
+	if rg, ok := os.LookupEnv("RESTORE_GRACE_PERIOD"); ok {
+		if d, err := time.ParseDuration(rg); err == nil {
+			cfg.restoreGracePeriod = d
+		}
+	}
+	if dr, ok := os.LookupEnv("DELETED_RETENTION"); ok {
+		if d, err := time.ParseDuration(dr); err == nil {
+			cfg.deletedRetention = d
+		}
+	}
+	if rt, ok := os.LookupEnv("REDIRECT_TYPE"); ok {
+		if code, err := strconv.Atoi(rt); err == nil {
+			cfg.redirectType = code
+		}
+	}
+	if iu, ok := os.LookupEnv("INACTIVE_URL"); ok {
+		cfg.inactiveURL = iu
+	}
+	if ch, ok := os.LookupEnv("COUNTRY_HEADER"); ok {
+		cfg.countryHeader = ch
+	}
+	if gd, ok := os.LookupEnv("GEOIP_DATABASE"); ok {
+		cfg.geoIPDatabase = gd
+	}
+	if tp, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.trustedProxies = tp
+	}
+	if mw, ok := os.LookupEnv("METADATA_WORKERS"); ok {
+		if n, err := strconv.Atoi(mw); err == nil {
+			cfg.metadataWorkers = n
+		}
+	}
+	if lci, ok := os.LookupEnv("LINK_CHECK_INTERVAL"); ok {
+		if d, err := time.ParseDuration(lci); err == nil {
+			cfg.linkCheckInterval = d
+		}
+	}
 
 	return cfg
 }
 
-const createUrlsTableQuery = `create table if not exists urls
+var createTablesQueries = []string{
+	`create table if not exists urls
 (
     id             serial primary key,
     url            text not null unique,
     user_id        uuid default null,
     correlation_id text default null,
     is_deleted     bool default false
-)`
+)`,
+	`create table if not exists users
+(
+    id            uuid primary key,
+    login         text not null unique,
+    password_hash text not null
+)`,
+	`create table if not exists orgs
+(
+    id   uuid primary key,
+    name text not null
+)`,
+	`create table if not exists org_members
+(
+    org_id  uuid not null references orgs (id) on delete cascade,
+    user_id uuid not null,
+    role    text not null,
+    primary key (org_id, user_id)
+)`,
+	`alter table urls add column if not exists org_id uuid default null references orgs (id)`,
+	`create table if not exists rate_limits
+(
+    key        text primary key,
+    tokens     double precision not null,
+    allowed    bool not null,
+    updated_at timestamptz not null
+)`,
+	`alter table urls add column if not exists created_at timestamptz not null default now()`,
+	`alter table urls add column if not exists canonical_url text default null`,
+	`create table if not exists url_revisions
+(
+    id         serial primary key,
+    url_id     int not null references urls (id) on delete cascade,
+    url        text not null,
+    editor_id  uuid not null,
+    created_at timestamptz not null default now()
+)`,
+	`alter table urls add column if not exists deleted_at timestamptz default null`,
+	`update urls set deleted_at = now() where is_deleted and deleted_at is null`,
+	`alter table urls add column if not exists redirect_type int not null default 0`,
+	`alter table urls add column if not exists passthrough text not null default ''`,
+	`create table if not exists utm_templates
+(
+    user_id  uuid not null,
+    name     text not null,
+    source   text not null default '',
+    medium   text not null default '',
+    campaign text not null default '',
+    term     text not null default '',
+    content  text not null default '',
+    primary key (user_id, name)
+)`,
+	`alter table urls add column if not exists utm_template text not null default ''`,
+	`alter table urls add column if not exists password_hash text not null default ''`,
+	`alter table urls add column if not exists max_clicks integer not null default 0`,
+	`alter table urls add column if not exists clicks_left integer not null default 0`,
+	`alter table urls add column if not exists not_before timestamptz`,
+	`alter table urls add column if not exists not_after timestamptz`,
+	`alter table urls add column if not exists rules jsonb not null default '[]'`,
+	`alter table urls add column if not exists variants jsonb not null default '[]'`,
+	`create table if not exists clicks
+(
+    id         bigserial primary key,
+    url_id     int not null references urls (id) on delete cascade,
+    variant    text not null default '',
+    created_at timestamptz not null default now()
+)`,
+	`create index if not exists clicks_url_id_created_at_idx on clicks (url_id, created_at)`,
+	`alter table clicks add column if not exists country text not null default ''`,
+	`alter table clicks add column if not exists city text not null default ''`,
+	`alter table clicks add column if not exists is_bot bool not null default false`,
+	`create table if not exists click_rollups
+(
+    url_id      int         not null references urls (id) on delete cascade,
+    granularity text        not null,
+    bucket      timestamptz not null,
+    clicks      int         not null default 0,
+    bot_clicks  int         not null default 0,
+    primary key (url_id, granularity, bucket)
+)`,
+	`alter table urls add column if not exists public_stats bool not null default false`,
+	`alter table urls add column if not exists metadata jsonb`,
+	`alter table urls add column if not exists link_status int not null default 0`,
+	`alter table urls add column if not exists link_failures int not null default 0`,
+	`alter table urls add column if not exists link_checked_at timestamptz`,
+	`create table if not exists link_checks
+(
+    id         bigserial primary key,
+    url_id     int         not null references urls (id) on delete cascade,
+    status     int         not null default 0,
+    error      text        not null default '',
+    checked_at timestamptz not null
+)`,
+	`create index if not exists link_checks_url_id_idx on link_checks (url_id, checked_at)`,
+	`create table if not exists webhooks
+(
+    id         uuid primary key,
+    user_id    uuid        not null,
+    url        text        not null,
+    secret     text        not null,
+    events     jsonb       not null default '[]',
+    created_at timestamptz not null default now()
+)`,
+	`create index if not exists webhooks_user_id_idx on webhooks (user_id)`,
+	`create table if not exists webhook_deliveries
+(
+    id              uuid primary key,
+    webhook_id      uuid        not null references webhooks (id) on delete cascade,
+    event           text        not null,
+    payload         text        not null,
+    status          text        not null,
+    attempts        int         not null default 0,
+    next_attempt_at timestamptz not null,
+    last_status     int         not null default 0,
+    last_error      text        not null default '',
+    created_at      timestamptz not null,
+    delivered_at    timestamptz
+)`,
+	`create index if not exists webhook_deliveries_due_idx on webhook_deliveries (status, next_attempt_at)`,
+	`create index if not exists webhook_deliveries_webhook_id_idx on webhook_deliveries (webhook_id, created_at)`,
+	`create table if not exists folders
+(
+    id         uuid primary key,
+    user_id    uuid        not null,
+    name       text        not null,
+    created_at timestamptz not null default now(),
+    unique (user_id, name)
+)`,
+	`alter table urls add column if not exists folder_id uuid references folders (id) on delete set null`,
+	`create index if not exists urls_folder_id_idx on urls (folder_id)`,
+	`create table if not exists tags
+(
+    id         uuid primary key,
+    user_id    uuid        not null,
+    name       text        not null,
+    created_at timestamptz not null default now(),
+    unique (user_id, name)
+)`,
+	`create table if not exists url_tags
+(
+    url_id int  not null references urls (id) on delete cascade,
+    tag_id uuid not null references tags (id) on delete cascade,
+    primary key (url_id, tag_id)
+)`,
+	`create index if not exists url_tags_tag_id_idx on url_tags (tag_id)`,
+	`alter table rate_limits add column if not exists expires_at timestamptz not null default now()`,
+	`create index if not exists rate_limits_expires_at_idx on rate_limits (expires_at)`,
+	`create index if not exists urls_user_id_idx on urls (user_id, created_at)`,
+	`alter table urls drop constraint if exists urls_url_key`,
+	`drop index if exists urls_canonical_url_key`,
+	`create unique index if not exists urls_plain_url_key on urls (url)
+    where redirect_type = 0 and passthrough = '' and utm_template = '' and password_hash = '' and max_clicks = 0
+        and not_before is null and not_after is null and rules = '[]' and variants = '[]'`,
+	`create unique index if not exists urls_plain_canonical_url_key on urls (canonical_url)
+    where redirect_type = 0 and passthrough = '' and utm_template = '' and password_hash = '' and max_clicks = 0
+        and not_before is null and not_after is null and rules = '[]' and variants = '[]'`,
+	`create index if not exists clicks_created_at_idx on clicks (created_at)`,
+}
 
 func checkDBTables(db *sqlx.DB, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	_, err := db.ExecContext(
-		ctx,
-		createUrlsTableQuery,
-	)
-	if err != nil {
-		return fmt.Errorf("creating urls table: %w", err)
+	for _, query := range createTablesQueries {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("creating tables: %w", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,115 @@
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type Location struct {
+	Country string
+	City    string
+}
+
+type Locator interface {
+	Lookup(ip net.IP) (Location, error)
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// DB looks up locations in a MaxMind format database file. The file is read into
+// memory, so it may be safely replaced on disk and picked up by Reload.
+type DB struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+}
+
+func Open(path string) (*DB, error) {
+	db := &DB{path: path}
+	if _, err := db.Reload(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *DB) Lookup(ip net.IP) (Location, error) {
+	db.mu.RLock()
+	reader := db.reader
+	db.mu.RUnlock()
+
+	var rec record
+	if err := reader.Lookup(ip, &rec); err != nil {
+		return Location{}, fmt.Errorf("lookup %s: %w", ip, err)
+	}
+
+	return Location{Country: rec.Country.ISOCode, City: rec.City.Names["en"]}, nil
+}
+
+// Reload reads the database file again if it was modified since the last load.
+func (db *DB) Reload() (bool, error) {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return false, fmt.Errorf("stat geoip database: %w", err)
+	}
+
+	db.mu.RLock()
+	unchanged := db.reader != nil && info.ModTime().Equal(db.modTime)
+	db.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return false, fmt.Errorf("read geoip database: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return false, fmt.Errorf("open geoip database: %w", err)
+	}
+
+	db.mu.Lock()
+	db.reader = reader
+	db.modTime = info.ModTime()
+	db.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch reloads the database every interval until ctx is done. A broken file
+// keeps the previously loaded database in use.
+func (db *DB) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		reloaded, err := db.Reload()
+		if err != nil {
+			log.Printf("reload geoip database: %v", err)
+		} else if reloaded {
+			log.Printf("reloaded geoip database %s", db.path)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	writeTestDB(t, path, Location{Country: "DE", City: "Berlin"}, Location{Country: "US"})
+
+	db, err := Open(path)
+	require.NoError(t, err)
+
+	loc, err := db.Lookup(net.ParseIP("1.2.3.4"))
+	require.NoError(t, err)
+	assert.Equal(t, Location{Country: "DE", City: "Berlin"}, loc)
+
+	loc, err = db.Lookup(net.ParseIP("200.1.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, Location{Country: "US"}, loc)
+
+	reloaded, err := db.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	writeTestDB(t, path, Location{Country: "FR", City: "Paris"}, Location{Country: "US"})
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	reloaded, err = db.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	loc, err = db.Lookup(net.ParseIP("1.2.3.4"))
+	require.NoError(t, err)
+	assert.Equal(t, Location{Country: "FR", City: "Paris"}, loc)
+}
+
+// writeTestDB writes an IPv4 database with a single tree node, so addresses
+// with the first bit unset resolve to left and others to right.
+func writeTestDB(t *testing.T, path string, left, right Location) {
+	t.Helper()
+
+	const nodeCount = 1
+
+	var data []byte
+	leftOffset := len(data)
+	data = append(data, encodeLocation(left)...)
+	rightOffset := len(data)
+	data = append(data, encodeLocation(right)...)
+
+	var db []byte
+	for _, offset := range []int{leftOffset, rightOffset} {
+		rec := offset + nodeCount + 16
+		db = append(db, byte(rec>>16), byte(rec>>8), byte(rec))
+	}
+	db = append(db, make([]byte, 16)...)
+	db = append(db, data...)
+	db = append(db, "\xab\xcd\xefMaxMind.com"...)
+	db = append(db, encodeMap(
+		"binary_format_major_version", encodeUint(5, 2),
+		"binary_format_minor_version", encodeUint(5, 0),
+		"build_epoch", encodeUint(6, 0),
+		"database_type", encodeString("Test-City"),
+		"ip_version", encodeUint(5, 4),
+		"node_count", encodeUint(6, nodeCount),
+		"record_size", encodeUint(5, 24),
+	)...)
+
+	require.NoError(t, os.WriteFile(path, db, 0600))
+}
+
+func encodeLocation(loc Location) []byte {
+	return encodeMap(
+		"country", encodeMap("iso_code", encodeString(loc.Country)),
+		"city", encodeMap("names", encodeMap("en", encodeString(loc.City))),
+	)
+}
+
+// encodeMap encodes alternating string keys and encoded values.
+func encodeMap(pairs ...interface{}) []byte {
+	b := []byte{byte(7<<5 | len(pairs)/2)}
+	for i := 0; i < len(pairs); i += 2 {
+		b = append(b, encodeString(pairs[i].(string))...)
+		b = append(b, pairs[i+1].([]byte)...)
+	}
+
+	return b
+}
+
+func encodeString(s string) []byte {
+	return append([]byte{byte(2<<5 | len(s))}, s...)
+}
+
+// encodeUint encodes v as uint16 (type 5) or uint32 (type 6).
+func encodeUint(typ byte, v uint32) []byte {
+	if typ == 5 {
+		return []byte{typ<<5 | 2, byte(v >> 8), byte(v)}
+	}
+
+	return []byte{typ<<5 | 4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
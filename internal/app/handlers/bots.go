@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+// botSignatures are lower case User-Agent tokens of link preview services and crawlers,
+// generic words like "bot" or "preview" also match in-app browsers of real users.
+var botSignatures = []string{
+	"facebookexternalhit",
+	"facebookcatalog",
+	"meta-externalagent",
+	"twitterbot",
+	"slackbot-linkexpanding",
+	"slack-imgproxy",
+	"linkedinbot",
+	"whatsapp/",
+	"telegrambot",
+	"discordbot",
+	"skypeuripreview",
+	"vkshare",
+	"embedly",
+	"quora link preview",
+	"pinterestbot",
+	"redditbot",
+	"mastodon/",
+	"googlebot",
+	"bingbot",
+	"applebot",
+	"duckduckbot",
+	"yandexbot",
+	"baiduspider",
+	"python-requests",
+	"go-http-client",
+	"headlesschrome",
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.URL}}">
+<meta property="og:title" content="{{.Title}}">
+{{- with .Description}}
+<meta property="og:description" content="{{.}}">
+<meta name="description" content="{{.}}">
+{{- end}}
+{{- with .Image}}
+<meta property="og:image" content="{{.}}">
+{{- end}}
+<title>{{.Title}}</title>
+</head>
+<body>
+<p><a href="{{.URL}}">{{.Title}}</a></p>
+{{- with .Description}}
+<p>{{.}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// isBot classifies requests of crawlers, link unfurlers and browser prefetches,
+// which must not be counted as clicks.
+func isBot(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	for _, sig := range botSignatures {
+		if strings.Contains(ua, sig) {
+			return true
+		}
+	}
+
+	for _, header := range []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"} {
+		if strings.Contains(strings.ToLower(r.Header.Get(header)), "prefetch") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// servePreview answers bots with a page describing the short link with metadata
+// of its destination, so link previews work without revealing the target or using up its clicks.
+func (h Handlers) servePreview(w http.ResponseWriter, shortURL storage.ShortURL) {
+	data := struct {
+		URL         string
+		Title       string
+		Description string
+		Image       string
+	}{
+		URL:   h.BaseURL + "/" + shortURL.ID,
+		Title: "Shared link",
+	}
+	if m := shortURL.Metadata; m != nil {
+		if m.Title != "" {
+			data.Title = m.Title
+		}
+		data.Description = m.Description
+		data.Image = m.Image
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	_ = previewTemplate.Execute(w, data)
+}
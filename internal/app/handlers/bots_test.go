@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{
+			name:    "browser",
+			headers: map[string]string{"User-Agent": windowsUA},
+			want:    false,
+		},
+		{
+			name:    "slack unfurler",
+			headers: map[string]string{"User-Agent": "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"},
+			want:    true,
+		},
+		{
+			name:    "facebook",
+			headers: map[string]string{"User-Agent": "facebookexternalhit/1.1"},
+			want:    true,
+		},
+		{
+			name:    "in-app browser",
+			headers: map[string]string{"User-Agent": "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 PreviewApp/2.1 Robot"},
+			want:    false,
+		},
+		{
+			name:    "twitter",
+			headers: map[string]string{"User-Agent": "Twitterbot/1.0"},
+			want:    true,
+		},
+		{
+			name:    "curl",
+			headers: map[string]string{"User-Agent": "curl/8.4.0"},
+			want:    false,
+		},
+		{
+			name:    "browser prefetch",
+			headers: map[string]string{"User-Agent": windowsUA, "Sec-Purpose": "prefetch;prerender"},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/1", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			assert.Equal(t, tt.want, isBot(req))
+		})
+	}
+}
+
+func TestRouter_GetURLBots(t *testing.T) {
+	h := getHandlers(nil)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	ctx := context.Background()
+	_, err := h.Storage.Create(ctx, storage.ShortURL{ID: "once", LongURL: "https://example.com/reset?token=secret", UserID: userID, MaxClicks: 1, ClicksLeft: 1})
+	require.NoError(t, err)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "blog", LongURL: "https://example.com/blog", UserID: userID})
+	require.NoError(t, err)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "sale", LongURL: "https://example.com/sale", UserID: userID, MaxClicks: 5, ClicksLeft: 5})
+	require.NoError(t, err)
+	_, err = h.Storage.SetMetadata(ctx, "sale", storage.LinkMetadata{
+		Title:       "Summer <sale>",
+		Description: "Everything half price",
+		Image:       "https://example.com/sale.png",
+	})
+	require.NoError(t, err)
+	r := NewRouter(h)
+
+	get := func(method, path, ua string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(http.MethodGet, "/once", "Slackbot-LinkExpanding 1.0")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `og:url" content="https://example.com/once"`)
+	assert.NotContains(t, w.Body.String(), "secret")
+
+	w = get(http.MethodGet, "/sale", "facebookexternalhit/1.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `og:title" content="Summer &lt;sale&gt;"`)
+	assert.Contains(t, w.Body.String(), `og:description" content="Everything half price"`)
+	assert.Contains(t, w.Body.String(), `og:image" content="https://example.com/sale.png"`)
+
+	w = get(http.MethodHead, "/once", windowsUA)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get(http.MethodGet, "/once", windowsUA)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	w = get(http.MethodGet, "/blog", "Twitterbot/1.0")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	w = get(http.MethodGet, "/blog", windowsUA)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls/blog/stats", nil), userID)
+	w = httptest.NewRecorder()
+	h.APIGetURLStats(w, withURLParams(req, map[string]string{"id": "blog"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var stats apiURLStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.Clicks)
+	assert.Equal(t, 1, stats.BotClicks)
+}
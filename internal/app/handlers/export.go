@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+
+	defaultStatsPeriod = 30 * 24 * time.Hour
+	maxHourlyPeriod    = 31 * 24 * time.Hour
+)
+
+var errInvalidStatsRange = errors.New("invalid stats range")
+
+// statsQuery holds from, to, granularity and format query parameters of stats endpoints.
+type statsQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity storage.Granularity
+	Format      string
+}
+
+type apiRollup struct {
+	Bucket    time.Time `json:"bucket"`
+	Clicks    int       `json:"clicks"`
+	BotClicks int       `json:"bot_clicks"`
+}
+
+type apiClick struct {
+	CreatedAt time.Time `json:"created_at"`
+	Variant   string    `json:"variant,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+	Bot       bool      `json:"bot"`
+}
+
+func parseStatsQuery(q url.Values, now time.Time) (statsQuery, error) {
+	sq := statsQuery{
+		To:          now,
+		Granularity: storage.Granularity(q.Get("granularity")),
+		Format:      q.Get("format"),
+	}
+	if sq.Format == "" {
+		sq.Format = formatJSON
+	}
+	if sq.Format != formatJSON && sq.Format != formatCSV {
+		return statsQuery{}, errInvalidStatsRange
+	}
+	if sq.Granularity != "" && !sq.Granularity.Valid() {
+		return statsQuery{}, errInvalidStatsRange
+	}
+
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return statsQuery{}, errInvalidStatsRange
+		}
+		sq.To = to
+	}
+	sq.From = sq.To.Add(-defaultStatsPeriod)
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return statsQuery{}, errInvalidStatsRange
+		}
+		sq.From = from
+	}
+	if !sq.From.Before(sq.To) {
+		return statsQuery{}, errInvalidStatsRange
+	}
+	if sq.Granularity == storage.GranularityHour && sq.To.Sub(sq.From) > maxHourlyPeriod {
+		return statsQuery{}, errInvalidStatsRange
+	}
+
+	return sq, nil
+}
+
+// APIGetUserStats reports rollups of all links of the user.
+func (h Handlers) APIGetUserStats(w http.ResponseWriter, r *http.Request) {
+	sq, err := parseStatsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if sq.Granularity == "" {
+		sq.Granularity = storage.GranularityDay
+	}
+
+	rollups, err := h.Storage.FindUserRollups(r.Context(), getUserIDFromRequest(r), sq.Granularity, sq.From, sq.To)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	writeRollups(w, sq.Format, rollups)
+}
+
+// APIGetURLClicks exports raw clicks of a link.
+func (h Handlers) APIGetURLClicks(w http.ResponseWriter, r *http.Request) {
+	shortURL, ok := h.userURL(w, r, isOrgMember)
+	if !ok {
+		return
+	}
+
+	sq, err := parseStatsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	clicks, err := h.Storage.FindClicks(r.Context(), shortURL.ID, sq.From, sq.To)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if sq.Format == formatCSV {
+		records := [][]string{{"created_at", "variant", "country", "city", "bot"}}
+		for _, c := range clicks {
+			records = append(records, []string{
+				c.CreatedAt.UTC().Format(time.RFC3339),
+				c.Variant,
+				c.Country,
+				c.City,
+				strconv.FormatBool(c.Bot),
+			})
+		}
+		writeCSV(w, records)
+		return
+	}
+
+	response := make([]apiClick, len(clicks))
+	for i, c := range clicks {
+		response[i] = apiClick{
+			CreatedAt: c.CreatedAt.UTC(),
+			Variant:   c.Variant,
+			Country:   c.Country,
+			City:      c.City,
+			Bot:       c.Bot,
+		}
+	}
+	writeJSON(w, response)
+}
+
+func newAPIRollups(rollups []storage.Rollup) []apiRollup {
+	series := make([]apiRollup, len(rollups))
+	for i, r := range rollups {
+		series[i] = apiRollup{Bucket: r.Bucket.UTC(), Clicks: r.Clicks, BotClicks: r.BotClicks}
+	}
+
+	return series
+}
+
+func writeRollups(w http.ResponseWriter, format string, rollups []storage.Rollup) {
+	if format == formatCSV {
+		records := [][]string{{"bucket", "clicks", "bot_clicks"}}
+		for _, r := range rollups {
+			records = append(records, []string{
+				r.Bucket.UTC().Format(time.RFC3339),
+				strconv.Itoa(r.Clicks),
+				strconv.Itoa(r.BotClicks),
+			})
+		}
+		writeCSV(w, records)
+		return
+	}
+
+	writeJSON(w, newAPIRollups(rollups))
+}
+
+func writeCSV(w http.ResponseWriter, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	_ = csv.NewWriter(w).WriteAll(records)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	resBody, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestHandlers_StatsExport(t *testing.T) {
+	h := getHandlers(nil)
+	ctx := context.Background()
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	_, err := h.Storage.Create(ctx, storage.ShortURL{ID: "1", LongURL: "https://example.com/", UserID: userID})
+	require.NoError(t, err)
+
+	day := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []storage.Click{
+		{URLID: "1", Country: "DE", CreatedAt: day.Add(9 * time.Hour)},
+		{URLID: "1", CreatedAt: day.Add(9*time.Hour + time.Minute), Bot: true},
+		{URLID: "1", CreatedAt: day.Add(26 * time.Hour)},
+	} {
+		require.NoError(t, h.Storage.RecordClick(ctx, c))
+	}
+	require.NoError(t, h.Storage.RollupClicks(ctx, time.Time{}))
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		target      string
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "hourly csv",
+			handler:     h.APIGetURLStats,
+			target:      "/api/user/urls/1/stats?granularity=hour&format=csv&from=2022-05-01T00:00:00Z&to=2022-05-02T00:00:00Z",
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body:        "bucket,clicks,bot_clicks\n2022-05-01T09:00:00Z,1,1\n",
+		},
+		{
+			name:       "hourly range too long",
+			handler:    h.APIGetURLStats,
+			target:     "/api/user/urls/1/stats?granularity=hour&from=2022-01-01T00:00:00Z&to=2022-05-02T00:00:00Z",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:        "raw clicks csv",
+			handler:     h.APIGetURLClicks,
+			target:      "/api/user/urls/1/clicks?format=csv&from=2022-05-01T00:00:00Z&to=2022-05-02T00:00:00Z",
+			statusCode:  http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body:        "created_at,variant,country,city,bot\n2022-05-01T09:00:00Z,,DE,,false\n2022-05-01T09:01:00Z,,,,true\n",
+		},
+		{
+			name:        "user rollups",
+			handler:     h.APIGetUserStats,
+			target:      "/api/user/stats?from=2022-05-01T00:00:00Z&to=2022-05-03T00:00:00Z",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"bucket":"2022-05-01T00:00:00Z","clicks":1,"bot_clicks":1},{"bucket":"2022-05-02T00:00:00Z","clicks":1,"bot_clicks":0}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodGet, tt.target, nil), userID)
+			w := httptest.NewRecorder()
+
+			tt.handler(w, withURLParams(req, map[string]string{"id": "1"}))
+
+			require.Equal(t, tt.statusCode, w.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls/1/stats?granularity=day&from=2022-05-01T00:00:00Z&to=2022-05-03T00:00:00Z", nil), userID)
+	w := httptest.NewRecorder()
+	h.APIGetURLStats(w, withURLParams(req, map[string]string{"id": "1"}))
+
+	var stats apiURLStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, []apiRollup{
+		{Bucket: day, Clicks: 1, BotClicks: 1},
+		{Bucket: day.Add(24 * time.Hour), Clicks: 1},
+	}, stats.Series)
+}
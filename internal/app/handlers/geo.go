@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/virp/go-shortener/internal/app/geoip"
+)
+
+// clientLocation resolves the client address with the GeoIP database, falling back
+// to the country header when the database has no answer. Like X-Forwarded-For the
+// header is trusted only when the request came from a trusted proxy.
+func (h Handlers) clientLocation(r *http.Request) geoip.Location {
+	var loc geoip.Location
+	if h.GeoIP != nil {
+		if ip := forwardedIP(r, h.TrustedProxies); ip != nil {
+			loc, _ = h.GeoIP.Lookup(ip)
+		}
+	}
+	if loc.Country == "" && h.CountryHeader != "" && h.fromTrustedProxy(r) {
+		loc.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(h.CountryHeader)))
+	}
+
+	return loc
+}
+
+// rateLimitKey identifies the client by its address. Cookies and other headers
+// are chosen by the client, so keying by them lets it bypass limits.
+func (h Handlers) rateLimitKey(r *http.Request) string {
+	if ip := forwardedIP(r, h.TrustedProxies); ip != nil {
+		return "ip:" + ip.String()
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// unlockRateLimitKey scopes password attempts of a client to the link being unlocked.
+func (h Handlers) unlockRateLimitKey(r *http.Request) string {
+	return "url:" + chi.URLParam(r, "id") + ":" + h.rateLimitKey(r)
+}
+
+// forwardedIP returns the client address, trusting X-Forwarded-For only when the
+// request came from a trusted proxy. The header is walked from the right, so the
+// first address not belonging to a trusted proxy is the one the proxies saw.
+func forwardedIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil || !isTrustedProxy(ip, trusted) {
+		return ip
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// fromTrustedProxy reports whether the request peer is one of TrustedProxies.
+func (h Handlers) fromTrustedProxy(r *http.Request) bool {
+	ip := net.ParseIP(clientIP(r))
+
+	return ip != nil && isTrustedProxy(ip, h.TrustedProxies)
+}
+
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
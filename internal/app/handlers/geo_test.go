@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/geoip"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+type staticLocator map[string]geoip.Location
+
+func (l staticLocator) Lookup(ip net.IP) (geoip.Location, error) {
+	return l[ip.String()], nil
+}
+
+func TestForwardedIP(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	trusted := []*net.IPNet{proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:1234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "untrusted peer cannot spoof",
+			remoteAddr: "203.0.113.7:1234",
+			forwarded:  "198.51.100.1",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  "198.51.100.1",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed hop before proxies",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  "192.0.2.99, 198.51.100.1, 10.0.0.3",
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/1", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			assert.Equal(t, tt.want, forwardedIP(req, trusted).String())
+		})
+	}
+}
+
+func TestHandlers_ClientLocationCountryHeader(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	h := Handlers{CountryHeader: "CF-IPCountry", TrustedProxies: []*net.IPNet{proxies}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:1234", want: "FR"},
+		{name: "untrusted client cannot spoof", remoteAddr: "203.0.113.7:1234", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/1", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("CF-IPCountry", "fr")
+
+			assert.Equal(t, tt.want, h.clientLocation(req).Country)
+		})
+	}
+}
+
+func TestRouter_URLStatsCountries(t *testing.T) {
+	h := getHandlers(nil)
+	h.GeoIP = staticLocator{
+		"198.51.100.1": {Country: "DE", City: "Berlin"},
+		"198.51.100.2": {Country: "DE", City: "Hamburg"},
+		"198.51.100.3": {Country: "US"},
+	}
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	_, err := h.Storage.Create(context.Background(), storage.ShortURL{ID: "1", LongURL: "https://example.com/", UserID: userID})
+	require.NoError(t, err)
+	r := NewRouter(h)
+
+	for _, ip := range []string{"198.51.100.1", "198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		req := httptest.NewRequest(http.MethodGet, "/1", nil)
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls/1/stats", nil), userID)
+	w := httptest.NewRecorder()
+	h.APIGetURLStats(w, withURLParams(req, map[string]string{"id": "1"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var stats apiURLStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, []apiCountryStats{
+		{Country: "DE", Clicks: 3, Cities: []apiCityStats{{City: "Berlin", Clicks: 2}, {City: "Hamburg", Clicks: 1}}},
+		{Country: "US", Clicks: 1},
+	}, stats.Countries)
+}
@@ -2,47 +2,101 @@ package handlers
 
 import (
 	"compress/flate"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jmoiron/sqlx"
+	"github.com/virp/go-shortener/internal/app/geoip"
+	"github.com/virp/go-shortener/internal/app/metadata"
+	"github.com/virp/go-shortener/internal/app/qr"
+	"github.com/virp/go-shortener/internal/app/ratelimit"
 	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/urlnorm"
+	"github.com/virp/go-shortener/internal/app/urlpolicy"
+	"github.com/virp/go-shortener/internal/app/webhooks"
 )
 
 type Handlers struct {
-	Storage storage.URLStorage
-	BaseURL string
-	Secret  string
-	DB      *sqlx.DB
+	Storage       storage.Storage
+	BaseURL       string
+	Secret        string
+	DB            *sqlx.DB
+	Limiter       ratelimit.Limiter
+	CreateLimit   ratelimit.Limit
+	RedirectLimit ratelimit.Limit
+	// PasswordLimit throttles attempts to unlock password protected links.
+	PasswordLimit ratelimit.Limit
+	// AnonymousQuota applies to users without an account, UserQuota to registered ones.
+	AnonymousQuota Quota
+	UserQuota      Quota
+	Policy         *urlpolicy.Policy
+	Normalization  urlnorm.Options
+	// RestoreGracePeriod limits how long deleted links may be restored.
+	RestoreGracePeriod  time.Duration
+	DefaultRedirectType int
+	// InactiveURL is where links outside their activation window lead, 404 if empty.
+	InactiveURL string
+	// CountryHeader names the request header with client country code set by one of TrustedProxies.
+	CountryHeader string
+	GeoIP         geoip.Locator
+	// TrustedProxies may set X-Forwarded-For with the client address and CountryHeader.
+	TrustedProxies []*net.IPNet
+	// QRCache keeps rendered QR codes, they are rendered on every request if nil.
+	QRCache *qr.Cache
+	// Metadata fetches destination titles and images of new links, disabled if nil.
+	Metadata *metadata.Pool
+	// Webhooks queues link events for user webhooks, disabled if nil.
+	Webhooks *webhooks.Dispatcher
 }
 
 type apiStoreRequest struct {
 	URL string `json:"url"`
+	// QR asks to include a QR code link in the response.
+	QR bool `json:"qr,omitempty"`
+	apiLinkOptions
 }
 
 type apiStoreResponse struct {
 	Result string `json:"result"`
+	QR     string `json:"qr,omitempty"`
 }
 
 type apiStoreBatchRequest struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	QR            bool   `json:"qr,omitempty"`
+	apiLinkOptions
 }
 
 type apiStoreBatchResponse struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
+	QR            string `json:"qr,omitempty"`
 }
 
 type apiUserURL struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string           `json:"short_url"`
+	OriginalURL string           `json:"original_url"`
+	OrgID       string           `json:"org_id,omitempty"`
+	State       string           `json:"state"`
+	NotBefore   *time.Time       `json:"not_before,omitempty"`
+	NotAfter    *time.Time       `json:"not_after,omitempty"`
+	Metadata    *apiLinkMetadata `json:"metadata,omitempty"`
+	// Broken is set when recent checks of the destination failed.
+	Broken        bool       `json:"broken,omitempty"`
+	LinkStatus    int        `json:"link_status,omitempty"`
+	LinkCheckedAt *time.Time `json:"link_checked_at,omitempty"`
+	FolderID      string     `json:"folder_id,omitempty"`
+	Tags          []apiTag   `json:"tags,omitempty"`
 }
 
 func NewRouter(h Handlers) *chi.Mux {
@@ -52,13 +106,65 @@ func NewRouter(h Handlers) *chi.Mux {
 	r.Use(DecompressRequest)
 	r.Use(IdentifyUser(h.Secret))
 
-	r.Post("/", h.StoreURL)
-	r.Get("/{id}", h.GetURL)
-
-	r.Post("/api/shorten", h.APIStoreURL)
-	r.Post("/api/shorten/batch", h.APIStoreURLBatch)
+	// Losing the limiter must not take redirects down, other limits fail closed.
+	limitCreate := RateLimit(h.Limiter, "create", h.CreateLimit, h.rateLimitKey, false)
+	limitRedirect := RateLimit(h.Limiter, "redirect", h.RedirectLimit, h.rateLimitKey, true)
+	limitPassword := RateLimit(h.Limiter, "password", h.PasswordLimit, h.unlockRateLimitKey, false)
+
+	r.With(limitCreate).Post("/", h.StoreURL)
+	r.With(limitRedirect).Get("/{id}", h.GetURL)
+	r.Get("/{id}+", h.PublicURLStats)
+	r.With(limitRedirect).Get("/{id}/*", h.GetURL)
+	r.With(limitRedirect).Head("/{id}", h.GetURL)
+	r.With(limitRedirect).Head("/{id}/*", h.GetURL)
+	r.With(limitPassword).Post("/{id}", h.UnlockURL)
+	r.With(limitPassword).Post("/{id}/*", h.UnlockURL)
+
+	r.With(limitCreate).Post("/api/shorten", h.APIStoreURL)
+	r.With(limitCreate).Post("/api/shorten/batch", h.APIStoreURLBatch)
 	r.Get("/api/user/urls", h.APIGetUserURLs)
 	r.Delete("/api/user/urls", h.APIDeleteUserURLs)
+	r.Post("/api/user/urls/restore", h.APIRestoreUserURLs)
+	r.Post("/api/user/urls/tags", h.APITagUserURLs)
+	r.Put("/api/user/urls/folder", h.APIMoveUserURLs)
+	r.Patch("/api/user/urls/{id}", h.APIUpdateUserURL)
+	r.Get("/api/user/urls/{id}/history", h.APIGetURLHistory)
+	r.Post("/api/user/urls/{id}/revert", h.APIRevertUserURL)
+	r.Put("/api/user/urls/{id}/rules", h.APISetURLRules)
+	r.Put("/api/user/urls/{id}/variants", h.APISetURLVariants)
+	r.Get("/api/user/urls/{id}/stats", h.APIGetURLStats)
+	r.Get("/api/user/urls/{id}/clicks", h.APIGetURLClicks)
+	r.Get("/api/user/urls/{id}/checks", h.APIGetURLChecks)
+	r.Put("/api/user/urls/{id}/public", h.APISetURLPublic)
+	r.Get("/api/qr/{id}", h.GetQRCode)
+	r.Post("/api/webhooks", h.APICreateWebhook)
+	r.Get("/api/webhooks", h.APIGetWebhooks)
+	r.Delete("/api/webhooks/{id}", h.APIDeleteWebhook)
+	r.Get("/api/webhooks/{id}/deliveries", h.APIGetWebhookDeliveries)
+	r.Post("/api/folders", h.APICreateFolder)
+	r.Get("/api/folders", h.APIGetFolders)
+	r.Patch("/api/folders/{id}", h.APIRenameFolder)
+	r.Delete("/api/folders/{id}", h.APIDeleteFolder)
+	r.Post("/api/tags", h.APICreateTag)
+	r.Get("/api/tags", h.APIGetTags)
+	r.Patch("/api/tags/{id}", h.APIRenameTag)
+	r.Delete("/api/tags/{id}", h.APIDeleteTag)
+	r.Get("/api/user/stats", h.APIGetUserStats)
+	r.Get("/api/user/quota", h.APIGetUserQuota)
+	r.Get("/api/user/utm-templates", h.APIGetUTMTemplates)
+	r.Get("/api/user/utm-templates/{name}", h.APIGetUTMTemplate)
+	r.Put("/api/user/utm-templates/{name}", h.APISaveUTMTemplate)
+	r.Delete("/api/user/utm-templates/{name}", h.APIDeleteUTMTemplate)
+
+	r.Post("/api/user/register", h.APIRegisterUser)
+	r.Post("/api/user/login", h.APILoginUser)
+	r.Post("/api/user/logout", h.APILogoutUser)
+
+	r.Post("/api/orgs", h.APICreateOrg)
+	r.Get("/api/orgs", h.APIGetUserOrgs)
+	r.Get("/api/orgs/{orgID}/members", h.APIGetOrgMembers)
+	r.Post("/api/orgs/{orgID}/members", h.APISetOrgMember)
+	r.Delete("/api/orgs/{orgID}/members/{userID}", h.APIRemoveOrgMember)
 
 	r.Get("/ping", h.CheckDB)
 
@@ -81,21 +187,51 @@ func (h Handlers) StoreURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.checkURLPolicy(w, r, u) {
+		return
+	}
+
 	userID := getUserIDFromRequest(r)
+	orgID, status := h.orgFromRequest(r, userID, storage.Role.CanEdit)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	quota, ok := h.linkQuota(w, r, userID, 1)
+	if !ok {
+		return
+	}
+
+	canonicalURL, err := urlnorm.Canonicalize(u, h.Normalization)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
 
 	shortURL := storage.ShortURL{
-		LongURL: u.String(),
-		UserID:  userID,
+		LongURL:      u.String(),
+		CanonicalURL: canonicalURL,
+		UserID:       userID,
+		OrgID:        orgID,
+	}
+	opts, err := linkOptionsFromQuery(r.URL.Query())
+	if err == nil {
+		err = h.applyLinkOptions(r, opts, &shortURL)
 	}
-	statusCode := http.StatusCreated
-	shortURL, err = h.Storage.Create(r.Context(), shortURL)
 	if err != nil {
-		if errors.Is(err, storage.ErrAlreadyExist) {
-			statusCode = http.StatusConflict
-		} else {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return
-		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	created, ok := h.createURLs(w, r, []storage.ShortURL{shortURL}, quota)
+	if !ok {
+		return
+	}
+	shortURL = created[0]
+	statusCode := http.StatusConflict
+	if shortURL.Inserted {
+		statusCode = http.StatusCreated
+		h.linkCreated(r.Context(), shortURL)
 	}
 
 	generatedShortURL := fmt.Sprintf("%s/%s", h.BaseURL, shortURL.ID)
@@ -118,8 +254,84 @@ func (h Handlers) GetURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Location", shortURL.LongURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	now := time.Now()
+	if !inActivationWindow(shortURL, now) {
+		if h.InactiveURL == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.Redirect(w, r, h.InactiveURL, http.StatusFound)
+		return
+	}
+
+	if shortURL.PasswordHash != "" && !h.hasLinkAccess(r, shortURL.ID) {
+		servePasswordForm(w, http.StatusOK, false)
+		return
+	}
+
+	// HEAD requests and bots must not use up clicks of limited links.
+	bot := isBot(r)
+	if (bot || r.Method == http.MethodHead) && shortURL.MaxClicks > 0 {
+		h.servePreview(w, shortURL)
+		return
+	}
+
+	loc := h.clientLocation(r)
+	target, variant := h.resolveTarget(w, r, shortURL, loc.Country, now)
+	suffix := chi.URLParam(r, "*")
+	if shortURL.Passthrough != "" {
+		target, err = passthroughTarget(target, shortURL.Passthrough, suffix, r.URL.Query())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	} else if suffix != "" {
+		http.NotFound(w, r)
+		return
+	}
+	target, err = h.withUTM(r, shortURL, target)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if shortURL.MaxClicks > 0 {
+		shortURL, err = h.Storage.ConsumeClick(r.Context(), shortURL.ID)
+		if errors.Is(err, storage.ErrExhausted) {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if r.Method != http.MethodHead {
+		// Losing a click must not break the redirect.
+		_ = h.Storage.RecordClick(r.Context(), storage.Click{
+			URLID:     shortURL.ID,
+			Variant:   variant,
+			Country:   loc.Country,
+			City:      loc.City,
+			Bot:       bot,
+			CreatedAt: now,
+		})
+		h.enqueue(webhooks.EventLinkClicked, shortURL, apiWebhookClick{
+			apiWebhookLink: h.newAPIWebhookLink(shortURL),
+			Variant:        variant,
+			Country:        loc.Country,
+			City:           loc.City,
+			Bot:            bot,
+			ClickedAt:      now,
+		})
+	}
+
+	code := h.redirectType(shortURL)
+	w.Header().Set("Cache-Control", redirectCacheControl(shortURL, code, now))
+	w.Header().Set("Location", target)
+	w.WriteHeader(code)
 }
 
 func (h Handlers) APIStoreURL(w http.ResponseWriter, r *http.Request) {
@@ -145,21 +357,47 @@ func (h Handlers) APIStoreURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.checkURLPolicy(w, r, u) {
+		return
+	}
+
 	userID := getUserIDFromRequest(r)
+	orgID, status := h.orgFromRequest(r, userID, storage.Role.CanEdit)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
 
-	shortURL := storage.ShortURL{
-		LongURL: u.String(),
-		UserID:  userID,
+	quota, ok := h.linkQuota(w, r, userID, 1)
+	if !ok {
+		return
 	}
-	statusCode := http.StatusCreated
-	shortURL, err = h.Storage.Create(r.Context(), shortURL)
+
+	canonicalURL, err := urlnorm.Canonicalize(u, h.Normalization)
 	if err != nil {
-		if errors.Is(err, storage.ErrAlreadyExist) {
-			statusCode = http.StatusConflict
-		} else {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return
-		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL := storage.ShortURL{
+		LongURL:      u.String(),
+		CanonicalURL: canonicalURL,
+		UserID:       userID,
+		OrgID:        orgID,
+	}
+	if err := h.applyLinkOptions(r, reqData.apiLinkOptions, &shortURL); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	created, ok := h.createURLs(w, r, []storage.ShortURL{shortURL}, quota)
+	if !ok {
+		return
+	}
+	shortURL = created[0]
+	statusCode := http.StatusConflict
+	if shortURL.Inserted {
+		statusCode = http.StatusCreated
+		h.linkCreated(r.Context(), shortURL)
 	}
 
 	generatedShortURL := fmt.Sprintf("%s/%s", h.BaseURL, shortURL.ID)
@@ -167,6 +405,9 @@ func (h Handlers) APIStoreURL(w http.ResponseWriter, r *http.Request) {
 	resData := apiStoreResponse{
 		Result: generatedShortURL,
 	}
+	if reqData.QR {
+		resData.QR = h.qrURL(shortURL.ID)
+	}
 
 	resBody, err := json.Marshal(resData)
 	if err != nil {
@@ -195,6 +436,11 @@ func (h Handlers) APIStoreURLBatch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	userID := getUserIDFromRequest(r)
+	orgID, status := h.orgFromRequest(r, userID, storage.Role.CanEdit)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
 
 	var urls []storage.ShortURL
 	for _, rd := range reqData {
@@ -203,26 +449,50 @@ func (h Handlers) APIStoreURLBatch(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
 		}
+		if !h.checkURLPolicy(w, r, u) {
+			return
+		}
+		canonicalURL, err := urlnorm.Canonicalize(u, h.Normalization)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
 		urlShort := storage.ShortURL{
 			LongURL:       u.String(),
+			CanonicalURL:  canonicalURL,
 			CorrelationID: rd.CorrelationID,
 			UserID:        userID,
+			OrgID:         orgID,
+		}
+		if err := h.applyLinkOptions(r, rd.apiLinkOptions, &urlShort); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
 		}
 		urls = append(urls, urlShort)
 	}
 
-	urls, err = h.Storage.CreateBatch(r.Context(), urls)
-	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	quota, ok := h.linkQuota(w, r, userID, len(urls))
+	if !ok {
+		return
+	}
+
+	urls, ok = h.createURLs(w, r, urls, quota)
+	if !ok {
 		return
 	}
 
 	var resData []apiStoreBatchResponse
-	for _, urlShort := range urls {
+	for i, urlShort := range urls {
 		rd := apiStoreBatchResponse{
 			CorrelationID: urlShort.CorrelationID,
 			ShortURL:      fmt.Sprintf("%s/%s", h.BaseURL, urlShort.ID),
 		}
+		if reqData[i].QR {
+			rd.QR = h.qrURL(urlShort.ID)
+		}
+		if urlShort.Inserted {
+			h.linkCreated(r.Context(), urlShort)
+		}
 		resData = append(resData, rd)
 	}
 
@@ -245,20 +515,28 @@ func (h Handlers) APIGetUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	urls := h.Storage.FindByUserID(r.Context(), userID)
+	orgID, status := h.orgFromRequest(r, userID, isOrgMember)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	urls, tags, err := h.findUserURLs(r, userID, orgID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
 	if len(urls) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	now := time.Now()
 	response := make([]apiUserURL, len(urls))
 	for i, shortURL := range urls {
-		apiURL := apiUserURL{
-			ShortURL:    fmt.Sprintf("%s/%s", h.BaseURL, shortURL.ID),
-			OriginalURL: shortURL.LongURL,
-		}
-		response[i] = apiURL
+		response[i] = h.newAPIUserURL(shortURL, now)
+		response[i].Tags = newAPITags(tags[shortURL.ID])
 	}
 
 	resBody, err := json.Marshal(response)
@@ -287,13 +565,56 @@ func (h Handlers) APIDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	deleted := h.deletableURLs(r, userID, ids)
 	go func() {
 		_ = h.Storage.DeleteBatch(r.Context(), userID, ids)
+		for _, shortURL := range deleted {
+			// The request context is done by now.
+			h.emit(context.Background(), webhooks.EventLinkDeleted, shortURL, h.newAPIWebhookLink(shortURL))
+		}
 	}()
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
+func (h Handlers) APIRestoreUserURLs(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var ids []string
+	err = json.Unmarshal(body, &ids)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	deletedAfter := time.Now().Add(-h.RestoreGracePeriod)
+	urls, err := h.Storage.RestoreBatch(r.Context(), getUserIDFromRequest(r), ids, deletedAfter)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	response := make([]apiUserURL, len(urls))
+	for i, shortURL := range urls {
+		response[i] = h.newAPIUserURL(shortURL, now)
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
 func (h Handlers) CheckDB(w http.ResponseWriter, r *http.Request) {
 	if err := h.DB.PingContext(r.Context()); err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
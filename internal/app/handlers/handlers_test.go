@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -85,6 +86,53 @@ func TestHandlers_StoreURL(t *testing.T) {
 	}
 }
 
+func TestHandlers_StoreURLCanonicalDuplicate(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+
+	tests := []struct {
+		name       string
+		longURL    string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "should store original url",
+			longURL:    "HTTP://Example.COM:80?b=2&a=1",
+			statusCode: http.StatusCreated,
+			response:   "https://example.com/1",
+		},
+		{
+			name:       "should detect canonical duplicate",
+			longURL:    "http://example.com/?a=1&b=2",
+			statusCode: http.StatusConflict,
+			response:   "https://example.com/1",
+		},
+		{
+			name:       "should store different url",
+			longURL:    "http://example.com/?a=2&b=1",
+			statusCode: http.StatusCreated,
+			response:   "https://example.com/2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com", bytes.NewBufferString(tt.longURL))
+			w := httptest.NewRecorder()
+
+			h.StoreURL(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.response, w.Body.String())
+		})
+	}
+
+	shortURL, err := h.Storage.GetByID(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://Example.COM:80?b=2&a=1", shortURL.LongURL)
+	assert.Equal(t, "http://example.com/?a=1&b=2", shortURL.CanonicalURL)
+}
+
 func TestHandlers_GetURL(t *testing.T) {
 	type want struct {
 		statusCode     int
@@ -141,6 +189,119 @@ func TestHandlers_GetURL(t *testing.T) {
 	}
 }
 
+func TestHandlers_GetURLRedirectType(t *testing.T) {
+	tests := []struct {
+		name         string
+		redirectType int
+		defaultType  int
+		statusCode   int
+		cacheControl string
+	}{
+		{
+			name:         "should use temporary redirect without defaults",
+			statusCode:   http.StatusTemporaryRedirect,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:         "should use server default",
+			defaultType:  http.StatusFound,
+			statusCode:   http.StatusFound,
+			cacheControl: "private, no-store",
+		},
+		{
+			name:         "should use link redirect type",
+			redirectType: http.StatusMovedPermanently,
+			defaultType:  http.StatusFound,
+			statusCode:   http.StatusMovedPermanently,
+			cacheControl: "private, max-age=3600",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := getHandlers(nil)
+			h.DefaultRedirectType = tt.defaultType
+			_, err := h.Storage.Create(context.Background(), storage.ShortURL{
+				ID:           "seo",
+				LongURL:      "https://example.com/landing",
+				RedirectType: tt.redirectType,
+			})
+			require.NoError(t, err)
+
+			req := withURLParams(httptest.NewRequest(http.MethodGet, "https://example.com/seo", nil), map[string]string{"id": "seo"})
+			w := httptest.NewRecorder()
+
+			h.GetURL(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.cacheControl, w.Header().Get("Cache-Control"))
+			assert.Equal(t, "https://example.com/landing", w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHandlers_APIStoreURLRedirectType(t *testing.T) {
+	h := getHandlers(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/a","redirect_type":308}`))
+	w := httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	shortURL, err := h.Storage.GetByID(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPermanentRedirect, shortURL.RedirectType)
+
+	req = httptest.NewRequest(http.MethodPost, "https://example.com/?redirect_type=200", bytes.NewBufferString("https://example.com/b"))
+	w = httptest.NewRecorder()
+	h.StoreURL(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlers_APIStoreURLDuplicateWithOptions(t *testing.T) {
+	h := getHandlers(nil)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	store := func(body string) (int, string) {
+		req := withUser(httptest.NewRequest(http.MethodPost, "https://example.com/api/shorten", bytes.NewBufferString(body)), userID)
+		w := httptest.NewRecorder()
+		h.APIStoreURL(w, req)
+		var res apiStoreResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res.Result
+	}
+
+	code, plain := store(`{"url":"https://dest.example.org/"}`)
+	require.Equal(t, http.StatusCreated, code)
+	code, result := store(`{"url":"https://dest.example.org/"}`)
+	require.Equal(t, http.StatusConflict, code)
+	require.Equal(t, plain, result)
+
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{name: "password", options: `"password":"secret"`},
+		{name: "max clicks", options: `"max_clicks":1`},
+		{name: "not before", options: `"not_before":"2030-01-01T00:00:00Z"`},
+		{name: "not after", options: `"not_after":"2030-01-01T00:00:00Z"`},
+		{name: "rules", options: `"rules":[{"country":"de","url":"https://dest.example.org/de"}]`},
+		{name: "variants", options: `"variants":[{"name":"a","url":"https://dest.example.org/a","weight":1},{"name":"b","url":"https://dest.example.org/b","weight":1}]`},
+		{name: "passthrough", options: `"passthrough":"keep"`},
+		{name: "redirect type", options: `"redirect_type":301`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, result := store(`{"url":"https://dest.example.org/",` + tt.options + `}`)
+			require.Equal(t, http.StatusCreated, code)
+			assert.NotEqual(t, plain, result)
+
+			shortURL, err := h.Storage.GetByID(context.Background(), strings.TrimPrefix(result, h.BaseURL+"/"))
+			require.NoError(t, err)
+			assert.True(t, shortURL.HasOptions())
+		})
+	}
+}
+
 func TestHandlers_APIStoreURL(t *testing.T) {
 	type want struct {
 		statusCode  int
@@ -236,3 +397,176 @@ func getHandlers(urls []storage.ShortURL) Handlers {
 
 	return h
 }
+
+func TestHandlers_GetURLMaxClicks(t *testing.T) {
+	h := getHandlers(nil)
+	r := NewRouter(h)
+
+	req := httptest.NewRequest(http.MethodPost, "/?max_clicks=2", bytes.NewBufferString("https://example.com/reset"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	for _, want := range []int{http.StatusTemporaryRedirect, http.StatusTemporaryRedirect, http.StatusGone} {
+		req = httptest.NewRequest(http.MethodGet, "/1", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/?max_clicks=-1", bytes.NewBufferString("https://example.com/other"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlers_GetURLActivationWindow(t *testing.T) {
+	h := getHandlers(nil)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	_, err := h.Storage.Create(ctx, storage.ShortURL{ID: "launch", LongURL: "https://example.com/launch", UserID: "user", NotBefore: &future})
+	require.NoError(t, err)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "promo", LongURL: "https://example.com/promo", UserID: "user", NotAfter: &past})
+	require.NoError(t, err)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "live", LongURL: "https://example.com/live", UserID: "user", NotBefore: &past, NotAfter: &future})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		id          string
+		inactiveURL string
+		statusCode  int
+		location    string
+	}{
+		{
+			name:       "should hide link before window",
+			id:         "launch",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:        "should redirect expired link to placeholder",
+			id:          "promo",
+			inactiveURL: "https://example.com/soon",
+			statusCode:  http.StatusFound,
+			location:    "https://example.com/soon",
+		},
+		{
+			name:        "should redirect link within window",
+			id:          "live",
+			inactiveURL: "https://example.com/soon",
+			statusCode:  http.StatusTemporaryRedirect,
+			location:    "https://example.com/live",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.InactiveURL = tt.inactiveURL
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.id, nil)
+			w := httptest.NewRecorder()
+
+			NewRouter(h).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls", nil), "user")
+	w := httptest.NewRecorder()
+	h.APIGetUserURLs(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var urls []apiUserURL
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &urls))
+	states := make(map[string]string)
+	for _, u := range urls {
+		states[u.OriginalURL] = u.State
+	}
+	assert.Equal(t, map[string]string{
+		"https://example.com/launch": linkStateScheduled,
+		"https://example.com/promo":  linkStateExpired,
+		"https://example.com/live":   linkStateActive,
+	}, states)
+}
+
+func TestRedirectCacheControl(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name     string
+		shortURL storage.ShortURL
+		code     int
+		want     string
+	}{
+		{
+			name: "temporary redirect",
+			code: http.StatusFound,
+			want: "private, no-store",
+		},
+		{
+			name: "permanent redirect",
+			code: http.StatusMovedPermanently,
+			want: "private, max-age=3600",
+		},
+		{
+			name:     "expiring soon",
+			shortURL: storage.ShortURL{NotAfter: at(10 * time.Minute)},
+			code:     http.StatusPermanentRedirect,
+			want:     "private, max-age=600",
+		},
+		{
+			name:     "expiring later",
+			shortURL: storage.ShortURL{NotAfter: at(48 * time.Hour)},
+			code:     http.StatusPermanentRedirect,
+			want:     "private, max-age=3600",
+		},
+		{
+			name:     "expiring now",
+			shortURL: storage.ShortURL{NotAfter: at(500 * time.Millisecond)},
+			code:     http.StatusPermanentRedirect,
+			want:     "private, no-store",
+		},
+		{
+			name:     "scheduled",
+			shortURL: storage.ShortURL{NotBefore: at(-time.Hour)},
+			code:     http.StatusMovedPermanently,
+			want:     "private, no-store",
+		},
+		{
+			name:     "password protected",
+			shortURL: storage.ShortURL{PasswordHash: "hash"},
+			code:     http.StatusMovedPermanently,
+			want:     "private, no-store",
+		},
+		{
+			name:     "utm template",
+			shortURL: storage.ShortURL{UTMTemplate: "newsletter"},
+			code:     http.StatusMovedPermanently,
+			want:     "private, no-store",
+		},
+		{
+			name:     "passthrough",
+			shortURL: storage.ShortURL{Passthrough: PassthroughKeep},
+			code:     http.StatusMovedPermanently,
+			want:     "private, no-store",
+		},
+		{
+			name:     "limited clicks",
+			shortURL: storage.ShortURL{MaxClicks: 5},
+			code:     http.StatusMovedPermanently,
+			want:     "private, no-store",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redirectCacheControl(tt.shortURL, tt.code, now))
+		})
+	}
+}
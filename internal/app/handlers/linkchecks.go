@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+type apiLinkCheck struct {
+	Status    int       `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	OK        bool      `json:"ok"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// APIGetURLChecks returns destination status history of a link, newest first.
+func (h Handlers) APIGetURLChecks(w http.ResponseWriter, r *http.Request) {
+	shortURL, ok := h.userURL(w, r, isOrgMember)
+	if !ok {
+		return
+	}
+
+	checks, err := h.Storage.FindLinkChecks(r.Context(), shortURL.ID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := make([]apiLinkCheck, 0, len(checks))
+	for _, c := range checks {
+		resData = append(resData, apiLinkCheck{
+			Status:    c.Status,
+			Error:     c.Error,
+			OK:        c.OK(),
+			CheckedAt: c.CheckedAt,
+		})
+	}
+
+	writeJSON(w, resData)
+}
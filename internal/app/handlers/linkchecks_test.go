@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestHandlers_LinkChecks(t *testing.T) {
+	h := getHandlers(nil)
+	ctx := context.Background()
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	_, err := h.Storage.Create(ctx, storage.ShortURL{ID: "1", LongURL: "https://dest.example.org/", UserID: userID})
+	require.NoError(t, err)
+	checkedAt := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, c := range []storage.LinkCheck{
+		{URLID: "1", Error: "connection refused", CheckedAt: checkedAt},
+		{URLID: "1", Status: http.StatusNotFound, CheckedAt: checkedAt.Add(time.Hour)},
+	} {
+		_, err := h.Storage.RecordLinkCheck(ctx, c)
+		require.NoError(t, err)
+	}
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls", nil), userID)
+	w := httptest.NewRecorder()
+	h.APIGetUserURLs(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"broken":true,"link_status":404,"link_checked_at":"2022-05-01T11:00:00Z"`)
+
+	req = withURLParams(withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls/1/checks", nil), userID), map[string]string{"id": "1"})
+	w = httptest.NewRecorder()
+	h.APIGetURLChecks(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"status":404,"ok":false,"checked_at":"2022-05-01T11:00:00Z"},
+		{"error":"connection refused","ok":false,"checked_at":"2022-05-01T10:00:00Z"}
+	]`, w.Body.String())
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiLinkOptions are optional link settings accepted on creation, either in
+// the JSON body of API requests or as query parameters of the plain text one.
+// Password is accepted only in the body, query strings end up in access logs.
+type apiLinkOptions struct {
+	RedirectType int               `json:"redirect_type,omitempty"`
+	Passthrough  string            `json:"passthrough,omitempty"`
+	UTMTemplate  string            `json:"utm_template,omitempty"`
+	Password     string            `json:"password,omitempty"`
+	MaxClicks    int               `json:"max_clicks,omitempty"`
+	NotBefore    *time.Time        `json:"not_before,omitempty"`
+	NotAfter     *time.Time        `json:"not_after,omitempty"`
+	Rules        []apiRedirectRule `json:"rules,omitempty"`
+	Variants     []apiVariant      `json:"variants,omitempty"`
+}
+
+var (
+	errInvalidRedirectType = errors.New("invalid redirect type")
+	errInvalidMaxClicks    = errors.New("invalid max clicks")
+	errInvalidWindow       = errors.New("invalid activation window")
+	errPasswordInQuery     = errors.New("password must be sent in the request body")
+)
+
+func linkOptionsFromQuery(q url.Values) (apiLinkOptions, error) {
+	var opts apiLinkOptions
+	if q.Has("password") {
+		return apiLinkOptions{}, errPasswordInQuery
+	}
+	if rt := q.Get("redirect_type"); rt != "" {
+		code, err := strconv.Atoi(rt)
+		if err != nil {
+			return apiLinkOptions{}, errInvalidRedirectType
+		}
+		opts.RedirectType = code
+	}
+	opts.Passthrough = q.Get("passthrough")
+	opts.UTMTemplate = q.Get("utm_template")
+	if mc := q.Get("max_clicks"); mc != "" {
+		n, err := strconv.Atoi(mc)
+		if err != nil {
+			return apiLinkOptions{}, errInvalidMaxClicks
+		}
+		opts.MaxClicks = n
+	}
+	for param, dst := range map[string]**time.Time{"not_before": &opts.NotBefore, "not_after": &opts.NotAfter} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return apiLinkOptions{}, errInvalidWindow
+			}
+			*dst = &t
+		}
+	}
+
+	return opts, nil
+}
+
+func (h Handlers) applyLinkOptions(r *http.Request, o apiLinkOptions, shortURL *storage.ShortURL) error {
+	if o.RedirectType != 0 && !validRedirectType(o.RedirectType) {
+		return errInvalidRedirectType
+	}
+	shortURL.RedirectType = o.RedirectType
+
+	if !validPassthrough(o.Passthrough) {
+		return errInvalidPassthrough
+	}
+	shortURL.Passthrough = o.Passthrough
+
+	if o.UTMTemplate != "" {
+		if _, err := h.Storage.GetUTMTemplate(r.Context(), shortURL.UserID, o.UTMTemplate); err != nil {
+			return fmt.Errorf("utm template %q: %w", o.UTMTemplate, err)
+		}
+	}
+	shortURL.UTMTemplate = o.UTMTemplate
+
+	if o.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(o.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("hash link password: %w", err)
+		}
+		shortURL.PasswordHash = string(hash)
+	}
+
+	if o.MaxClicks < 0 {
+		return errInvalidMaxClicks
+	}
+	shortURL.MaxClicks = o.MaxClicks
+	shortURL.ClicksLeft = o.MaxClicks
+
+	if o.NotBefore != nil && o.NotAfter != nil && !o.NotAfter.After(*o.NotBefore) {
+		return errInvalidWindow
+	}
+	shortURL.NotBefore = o.NotBefore
+	shortURL.NotAfter = o.NotAfter
+
+	rules, err := h.redirectRules(r, o.Rules)
+	if err != nil {
+		return err
+	}
+	shortURL.Rules = rules
+
+	variants, err := h.linkVariants(r, o.Variants)
+	if err != nil {
+		return err
+	}
+	shortURL.Variants = variants
+
+	return nil
+}
+
+const (
+	linkStateActive    = "active"
+	linkStateScheduled = "scheduled"
+	linkStateExpired   = "expired"
+	linkStateExhausted = "exhausted"
+	linkStateDeleted   = "deleted"
+)
+
+func inActivationWindow(shortURL storage.ShortURL, now time.Time) bool {
+	if shortURL.NotBefore != nil && now.Before(*shortURL.NotBefore) {
+		return false
+	}
+	if shortURL.NotAfter != nil && !now.Before(*shortURL.NotAfter) {
+		return false
+	}
+
+	return true
+}
+
+func linkState(shortURL storage.ShortURL, now time.Time) string {
+	switch {
+	case shortURL.IsDeleted:
+		return linkStateDeleted
+	case shortURL.NotBefore != nil && now.Before(*shortURL.NotBefore):
+		return linkStateScheduled
+	case !inActivationWindow(shortURL, now):
+		return linkStateExpired
+	case shortURL.MaxClicks > 0 && shortURL.ClicksLeft <= 0:
+		return linkStateExhausted
+	}
+
+	return linkStateActive
+}
+
+func (h Handlers) newAPIUserURL(shortURL storage.ShortURL, now time.Time) apiUserURL {
+	return apiUserURL{
+		ShortURL:      fmt.Sprintf("%s/%s", h.BaseURL, shortURL.ID),
+		OriginalURL:   shortURL.LongURL,
+		OrgID:         shortURL.OrgID,
+		State:         linkState(shortURL, now),
+		NotBefore:     shortURL.NotBefore,
+		NotAfter:      shortURL.NotAfter,
+		Metadata:      newAPILinkMetadata(shortURL.Metadata),
+		Broken:        shortURL.Broken(),
+		LinkStatus:    shortURL.LinkStatus,
+		LinkCheckedAt: shortURL.LinkCheckedAt,
+		FolderID:      shortURL.FolderID,
+	}
+}
+
+func validRedirectType(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+func (h Handlers) redirectType(shortURL storage.ShortURL) int {
+	if shortURL.RedirectType != 0 {
+		return shortURL.RedirectType
+	}
+	if h.DefaultRedirectType != 0 {
+		return h.DefaultRedirectType
+	}
+
+	return http.StatusTemporaryRedirect
+}
+
+// maxRedirectCacheAge bounds how long a browser keeps following an edited or deleted link.
+const maxRedirectCacheAge = time.Hour
+
+// redirectCacheControl lets browsers cache permanent redirects, while temporary ones and
+// links limited by clicks or password, scheduled or varying by visitor or request must
+// reach the server on every click. Shared caches are not allowed, they cannot be purged
+// when a link is edited or deleted.
+func redirectCacheControl(shortURL storage.ShortURL, code int, now time.Time) string {
+	const noStore = "private, no-store"
+
+	if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+		return noStore
+	}
+	if shortURL.MaxClicks > 0 || len(shortURL.Rules) > 0 || len(shortURL.Variants) > 0 {
+		return noStore
+	}
+	if shortURL.NotBefore != nil || shortURL.PasswordHash != "" || shortURL.UTMTemplate != "" || shortURL.Passthrough != "" {
+		return noStore
+	}
+
+	maxAge := maxRedirectCacheAge
+	if shortURL.NotAfter != nil && shortURL.NotAfter.Sub(now) < maxAge {
+		maxAge = shortURL.NotAfter.Sub(now)
+	}
+	if maxAge < time.Second {
+		return noStore
+	}
+
+	return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+}
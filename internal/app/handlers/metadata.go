@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+type apiLinkMetadata struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Favicon     string    `json:"favicon,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+func newAPILinkMetadata(m *storage.LinkMetadata) *apiLinkMetadata {
+	if m == nil {
+		return nil
+	}
+
+	return &apiLinkMetadata{
+		Title:       m.Title,
+		Description: m.Description,
+		Favicon:     m.Favicon,
+		Image:       m.Image,
+		FetchedAt:   m.FetchedAt,
+	}
+}
+
+// fetchMetadata schedules capturing the destination page of shortURL,
+// links created while the queue is full stay without metadata. Destinations
+// of click limited and protected links are one-time or private, they are not requested.
+func (h Handlers) fetchMetadata(shortURL storage.ShortURL) {
+	if shortURL.MaxClicks > 0 || shortURL.PasswordHash != "" {
+		return
+	}
+	if h.Metadata != nil {
+		h.Metadata.Enqueue(shortURL.ID, shortURL.LongURL)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/metadata"
+	"github.com/virp/go-shortener/internal/app/safehttp"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestHandlers_APIStoreURLFetchesMetadata(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Destination</title><meta property="og:image" content="/card.png"></head></html>`))
+	}))
+	defer dest.Close()
+
+	h := getHandlers(nil)
+	fetcher := metadata.NewFetcher(safehttp.NewClient(time.Second, true), 1024)
+	h.Metadata = metadata.NewPool(fetcher, func(ctx context.Context, id string, m metadata.Metadata) error {
+		_, err := h.Storage.SetMetadata(ctx, id, storage.LinkMetadata(m))
+		return err
+	}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Metadata.Run(ctx, 1)
+
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	for _, body := range []string{
+		`{"url":"` + dest.URL + `/reset","max_clicks":1}`,
+		`{"url":"` + dest.URL + `/private","password":"secret"}`,
+		`{"url":"` + dest.URL + `/page"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		h.APIStoreURL(w, withUser(req, userID))
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	var urls []apiUserURL
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		h.APIGetUserURLs(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls", nil), userID))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &urls))
+		for _, u := range urls {
+			if u.Metadata != nil {
+				urls = []apiUserURL{u}
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "Destination", urls[0].Metadata.Title)
+	assert.Equal(t, dest.URL+"/card.png", urls[0].Metadata.Image)
+	assert.Equal(t, dest.URL+"/favicon.ico", urls[0].Metadata.Favicon)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/page"}, requested)
+}
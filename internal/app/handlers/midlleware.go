@@ -11,9 +11,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/virp/go-shortener/internal/app/ratelimit"
 )
 
 func DecompressRequest(next http.Handler) http.Handler {
@@ -60,29 +63,81 @@ func IdentifyUser(secret string) func(next http.Handler) http.Handler {
 				}
 			}
 
-			nonce, err := generateRandom(aesGCM.NonceSize())
-			if err != nil {
+			user := uuid.NewString()
+			if err := setUserCookie(w, aesGCM, user); err != nil {
 				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				return
 			}
 
-			user := uuid.NewString()
-			encUser := aesGCM.Seal(nil, nonce, []byte(user), nil)
+			ctx := context.WithValue(r.Context(), userKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			msg := append(encUser, nonce...)
+// RateLimit rejects requests exceeding limit with 429 Too Many Requests.
+// Buckets are separated by name, so creation and redirects are limited independently,
+// and key identifies the client within them. When the limiter fails requests are
+// rejected with 503 Service Unavailable unless failOpen is set.
+func RateLimit(limiter ratelimit.Limiter, name string, limit ratelimit.Limit, key func(*http.Request) string, failOpen bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil || !limit.Enabled() {
+			return next
+		}
 
-			c = &http.Cookie{
-				Name:  "user",
-				Value: hex.EncodeToString(msg),
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limiter.Allow(r.Context(), name+":"+key(r), limit)
+			if err != nil {
+				if failOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
 			}
-			http.SetCookie(w, c)
 
-			ctx := context.WithValue(r.Context(), userKey, user)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(res.RetryAfter.Seconds())))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func setUserCookie(w http.ResponseWriter, aesGCM cipher.AEAD, user string) error {
+	nonce, err := generateRandom(aesGCM.NonceSize())
+	if err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+
+	encUser := aesGCM.Seal(nil, nonce, []byte(user), nil)
+
+	msg := append(encUser, nonce...)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:  "user",
+		Value: hex.EncodeToString(msg),
+	})
+
+	return nil
+}
+
 func generateRandom(size int) ([]byte, error) {
 	b := make([]byte, size)
 	_, err := rand.Read(b)
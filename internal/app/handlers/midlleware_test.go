@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	h := Handlers{TrustedProxies: []*net.IPNet{proxies}}
+
+	limit := ratelimit.Limit{Requests: 2, Per: time.Minute}
+	handler := RateLimit(ratelimit.NewMemoryLimiter(), "create", limit, h.rateLimitKey, false)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		}),
+	)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		statusCode int
+		remaining  string
+	}{
+		{
+			name:       "first request",
+			remoteAddr: "192.0.2.1:1234",
+			statusCode: http.StatusCreated,
+			remaining:  "1",
+		},
+		{
+			name:       "second request from another port",
+			remoteAddr: "192.0.2.1:4321",
+			statusCode: http.StatusCreated,
+			remaining:  "0",
+		},
+		{
+			name:       "limited request",
+			remoteAddr: "192.0.2.1:1234",
+			statusCode: http.StatusTooManyRequests,
+			remaining:  "0",
+		},
+		{
+			name:       "client headers do not reset limit",
+			remoteAddr: "192.0.2.1:1234",
+			headers: map[string]string{
+				"X-API-Key":       "key",
+				"Cookie":          "user=0011",
+				"X-Forwarded-For": "198.51.100.7",
+			},
+			statusCode: http.StatusTooManyRequests,
+			remaining:  "0",
+		},
+		{
+			name:       "another client",
+			remoteAddr: "192.0.2.2:1234",
+			statusCode: http.StatusCreated,
+			remaining:  "1",
+		},
+		{
+			name:       "client behind trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1"},
+			statusCode: http.StatusTooManyRequests,
+			remaining:  "0",
+		},
+		{
+			name:       "another client behind trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.3"},
+			statusCode: http.StatusCreated,
+			remaining:  "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, tt.remaining, w.Header().Get("RateLimit-Remaining"))
+			if tt.statusCode == http.StatusTooManyRequests {
+				assert.Equal(t, "30", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("limiter unavailable")
+}
+
+func TestRateLimit_LimiterFailure(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 2, Per: time.Minute}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	key := func(r *http.Request) string { return "ip:" + clientIP(r) }
+
+	tests := []struct {
+		name       string
+		failOpen   bool
+		statusCode int
+	}{
+		{
+			name:       "fail open",
+			failOpen:   true,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "fail closed",
+			statusCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			RateLimit(failingLimiter{}, "create", limit, key, tt.failOpen)(next).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+type apiCreateOrgRequest struct {
+	Name string `json:"name"`
+}
+
+type apiOrg struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type apiSetOrgMemberRequest struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+type apiOrgMember struct {
+	UserID string `json:"user_id"`
+	Login  string `json:"login"`
+	Role   string `json:"role"`
+}
+
+func (h Handlers) APICreateOrg(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiCreateOrgRequest
+	if err := json.Unmarshal(body, &reqData); err != nil || reqData.Name == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	userID := getUserIDFromRequest(r)
+	if _, err := h.Storage.GetUserByID(r.Context(), userID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	org, err := h.Storage.CreateOrg(r.Context(), storage.Org{ID: uuid.NewString(), Name: reqData.Name}, userID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resBody, err := json.Marshal(apiOrg{ID: org.ID, Name: org.Name, Role: string(storage.RoleOwner)})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APIGetUserOrgs(w http.ResponseWriter, r *http.Request) {
+	orgs := h.Storage.FindUserOrgs(r.Context(), getUserIDFromRequest(r))
+	if len(orgs) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response := make([]apiOrg, len(orgs))
+	for i, org := range orgs {
+		response[i] = apiOrg{ID: org.ID, Name: org.Name, Role: string(org.Role)}
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APIGetOrgMembers(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgID")
+	if status := h.checkOrgRole(r, orgID, getUserIDFromRequest(r), isOrgMember); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	members := h.Storage.FindOrgMembers(r.Context(), orgID)
+	response := make([]apiOrgMember, len(members))
+	for i, member := range members {
+		var login string
+		if user, err := h.Storage.GetUserByID(r.Context(), member.UserID); err == nil {
+			login = user.Login
+		}
+		response[i] = apiOrgMember{UserID: member.UserID, Login: login, Role: string(member.Role)}
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APISetOrgMember(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiSetOrgMemberRequest
+	if err := json.Unmarshal(body, &reqData); err != nil || !storage.Role(reqData.Role).Valid() {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	orgID := chi.URLParam(r, "orgID")
+	userID := getUserIDFromRequest(r)
+	if status := h.checkOrgRole(r, orgID, userID, storage.Role.CanManage); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	user, err := h.Storage.GetUserByLogin(r.Context(), reqData.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if user.ID == userID && storage.Role(reqData.Role) != storage.RoleOwner && h.isLastOrgOwner(r, orgID, userID) {
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		return
+	}
+
+	member := storage.OrgMember{OrgID: orgID, UserID: user.ID, Role: storage.Role(reqData.Role)}
+	if err := h.Storage.SetOrgMember(r.Context(), member); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h Handlers) APIRemoveOrgMember(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "orgID")
+	memberID := chi.URLParam(r, "userID")
+	userID := getUserIDFromRequest(r)
+
+	// Members may leave an organization on their own, otherwise only owners remove members.
+	need := storage.Role.CanManage
+	if memberID == userID {
+		need = isOrgMember
+	}
+	if status := h.checkOrgRole(r, orgID, userID, need); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if h.isLastOrgOwner(r, orgID, memberID) {
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		return
+	}
+
+	if err := h.Storage.RemoveOrgMember(r.Context(), orgID, memberID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// checkOrgRole returns an HTTP status code describing why userID may not act
+// on the organization, or zero when the member role satisfies need.
+func (h Handlers) checkOrgRole(r *http.Request, orgID, userID string, need func(storage.Role) bool) int {
+	member, err := h.Storage.GetOrgMember(r.Context(), orgID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return http.StatusForbidden
+		}
+		return http.StatusInternalServerError
+	}
+	if !need(member.Role) {
+		return http.StatusForbidden
+	}
+
+	return 0
+}
+
+// orgFromRequest resolves the optional org query parameter used to act on organization links.
+func (h Handlers) orgFromRequest(r *http.Request, userID string, need func(storage.Role) bool) (string, int) {
+	orgID := r.URL.Query().Get("org")
+	if orgID == "" {
+		return "", 0
+	}
+
+	return orgID, h.checkOrgRole(r, orgID, userID, need)
+}
+
+func (h Handlers) isLastOrgOwner(r *http.Request, orgID, userID string) bool {
+	var owners int
+	var isOwner bool
+	for _, member := range h.Storage.FindOrgMembers(r.Context(), orgID) {
+		if member.Role == storage.RoleOwner {
+			owners++
+			isOwner = isOwner || member.UserID == userID
+		}
+	}
+
+	return isOwner && owners == 1
+}
+
+func isOrgMember(storage.Role) bool {
+	return true
+}
+
+// checkURLAccess returns an HTTP status code describing why userID may not act on url,
+// or zero when userID owns url or has a suitable role in the organization owning it.
+func (h Handlers) checkURLAccess(r *http.Request, url storage.ShortURL, userID string, need func(storage.Role) bool) int {
+	if url.OrgID == "" {
+		if url.UserID != userID {
+			return http.StatusForbidden
+		}
+		return 0
+	}
+
+	return h.checkOrgRole(r, url.OrgID, userID, need)
+}
+
+// userURL loads the short URL from the id route parameter, writes an error response
+// and returns false when it does not exist or the current user may not access it.
+func (h Handlers) userURL(w http.ResponseWriter, r *http.Request, need func(storage.Role) bool) (storage.ShortURL, bool) {
+	shortURL, err := h.Storage.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return storage.ShortURL{}, false
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return storage.ShortURL{}, false
+	}
+
+	if status := h.checkURLAccess(r, shortURL, getUserIDFromRequest(r), need); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return storage.ShortURL{}, false
+	}
+
+	return shortURL, true
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestHandlers_OrgLinks(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	ctx := context.Background()
+	for _, u := range []storage.User{{ID: "owner", Login: "owner"}, {ID: "editor", Login: "editor"}, {ID: "viewer", Login: "viewer"}} {
+		_, err := h.Storage.CreateUser(ctx, u)
+		require.NoError(t, err)
+	}
+	org, err := h.Storage.CreateOrg(ctx, storage.Org{ID: "org", Name: "Marketing"}, "owner")
+	require.NoError(t, err)
+
+	for login, role := range map[string]string{"editor": "editor", "viewer": "viewer"} {
+		req := withUser(httptest.NewRequest(
+			http.MethodPost,
+			"https://example.com/api/orgs/org/members",
+			bytes.NewBufferString(`{"login":"`+login+`","role":"`+role+`"}`),
+		), "owner")
+		req = withURLParams(req, map[string]string{"orgID": org.ID})
+		w := httptest.NewRecorder()
+		h.APISetOrgMember(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	tests := []struct {
+		name       string
+		userID     string
+		statusCode int
+	}{
+		{
+			name:       "viewer should not create org links",
+			userID:     "viewer",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "outsider should not create org links",
+			userID:     "outsider",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "editor should create org links",
+			userID:     "editor",
+			statusCode: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(
+				http.MethodPost,
+				"https://example.com/api/shorten?org=org",
+				bytes.NewBufferString(`{"url":"https://example.com/`+tt.userID+`"}`),
+			), tt.userID)
+			w := httptest.NewRecorder()
+
+			h.APIStoreURL(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+
+	urls := h.Storage.FindByOrgID(ctx, "org")
+	require.Len(t, urls, 1)
+
+	req := withUser(httptest.NewRequest(http.MethodGet, "https://example.com/api/user/urls?org=org", nil), "viewer")
+	w := httptest.NewRecorder()
+	h.APIGetUserURLs(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"org_id":"org"`)
+
+	require.NoError(t, h.Storage.DeleteBatch(ctx, "viewer", []string{urls[0].ID}))
+	u, err := h.Storage.GetByID(ctx, urls[0].ID)
+	require.NoError(t, err)
+	assert.False(t, u.IsDeleted)
+
+	require.NoError(t, h.Storage.DeleteBatch(ctx, "owner", []string{urls[0].ID}))
+	u, err = h.Storage.GetByID(ctx, urls[0].ID)
+	require.NoError(t, err)
+	assert.True(t, u.IsDeleted)
+}
+
+func TestHandlers_APIRemoveOrgMember(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	ctx := context.Background()
+	_, err := h.Storage.CreateOrg(ctx, storage.Org{ID: "org", Name: "Marketing"}, "owner")
+	require.NoError(t, err)
+	require.NoError(t, h.Storage.SetOrgMember(ctx, storage.OrgMember{OrgID: "org", UserID: "viewer", Role: storage.RoleViewer}))
+
+	tests := []struct {
+		name       string
+		userID     string
+		memberID   string
+		statusCode int
+	}{
+		{
+			name:       "viewer should not remove others",
+			userID:     "viewer",
+			memberID:   "owner",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "last owner should not leave",
+			userID:     "owner",
+			memberID:   "owner",
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "viewer should leave",
+			userID:     "viewer",
+			memberID:   "viewer",
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodDelete, "https://example.com/", nil), tt.userID)
+			req = withURLParams(req, map[string]string{"orgID": "org", "userID": tt.memberID})
+			w := httptest.NewRecorder()
+
+			h.APIRemoveOrgMember(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+}
+
+func withURLParams(r *http.Request, params map[string]string) *http.Request {
+	rCtx := chi.NewRouteContext()
+	for k, v := range params {
+		rCtx.URLParams.Add(k, v)
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rCtx))
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Passthrough modes decide which value wins when the visitor query
+// and the destination define the same parameter.
+const (
+	PassthroughKeep     = "keep"
+	PassthroughOverride = "override"
+	PassthroughAppend   = "append"
+)
+
+var errInvalidPassthrough = errors.New("invalid passthrough mode")
+
+func validPassthrough(mode string) bool {
+	switch mode {
+	case "", PassthroughKeep, PassthroughOverride, PassthroughAppend:
+		return true
+	}
+
+	return false
+}
+
+// passthroughTarget appends the path suffix and query of the visitor request to target.
+func passthroughTarget(target, mode, suffix string, query url.Values) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+
+	if suffix != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(suffix, "/")
+		u.RawPath = ""
+	}
+
+	if len(query) > 0 {
+		merged := u.Query()
+		for key, values := range query {
+			_, exists := merged[key]
+			switch {
+			case !exists || mode == PassthroughOverride:
+				merged[key] = values
+			case mode == PassthroughAppend:
+				merged[key] = append(merged[key], values...)
+			}
+		}
+		u.RawQuery = merged.Encode()
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestPassthroughTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		mode   string
+		suffix string
+		query  string
+		want   string
+	}{
+		{
+			name:   "path suffix",
+			target: "https://example.com/docs/",
+			mode:   PassthroughKeep,
+			suffix: "extra/path",
+			want:   "https://example.com/docs/extra/path",
+		},
+		{
+			name:   "new query params",
+			target: "https://example.com/?id=1",
+			mode:   PassthroughKeep,
+			query:  "utm_source=x",
+			want:   "https://example.com/?id=1&utm_source=x",
+		},
+		{
+			name:   "keep destination value",
+			target: "https://example.com/?utm_source=site",
+			mode:   PassthroughKeep,
+			query:  "utm_source=x",
+			want:   "https://example.com/?utm_source=site",
+		},
+		{
+			name:   "override destination value",
+			target: "https://example.com/?utm_source=site",
+			mode:   PassthroughOverride,
+			query:  "utm_source=x",
+			want:   "https://example.com/?utm_source=x",
+		},
+		{
+			name:   "append values",
+			target: "https://example.com/?tag=a",
+			mode:   PassthroughAppend,
+			query:  "tag=b",
+			want:   "https://example.com/?tag=a&tag=b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			got, err := passthroughTarget(tt.target, tt.mode, tt.suffix, query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRouter_GetURLPassthrough(t *testing.T) {
+	h := getHandlers(nil)
+	ctx := context.Background()
+	_, err := h.Storage.Create(ctx, storage.ShortURL{ID: "app", LongURL: "https://example.com/app", Passthrough: PassthroughKeep})
+	require.NoError(t, err)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "plain", LongURL: "https://example.com/plain"})
+	require.NoError(t, err)
+	r := NewRouter(h)
+
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+		location   string
+	}{
+		{
+			name:       "should forward path and query",
+			path:       "/app/screen/1?utm_source=x",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://example.com/app/screen/1?utm_source=x",
+		},
+		{
+			name:       "should ignore query without passthrough",
+			path:       "/plain?utm_source=x",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://example.com/plain",
+		},
+		{
+			name:       "should not forward path without passthrough",
+			path:       "/plain/extra",
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+		})
+	}
+}
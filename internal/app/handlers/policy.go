@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/virp/go-shortener/internal/app/urlpolicy"
+)
+
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+}
+
+// checkURLPolicy writes 422 Unprocessable Entity describing the violation and returns false
+// when the target URL is rejected by the configured policy.
+func (h Handlers) checkURLPolicy(w http.ResponseWriter, r *http.Request, u *url.URL) bool {
+	if h.Policy == nil {
+		return true
+	}
+
+	err := h.Policy.Check(r.Context(), u)
+	if err == nil {
+		return true
+	}
+
+	var v *urlpolicy.Violation
+	if !errors.As(err, &v) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+
+	writeAPIError(w, http.StatusUnprocessableEntity, apiError{Code: v.Code, Message: v.Message, URL: u.String()})
+
+	return false
+}
+
+func writeAPIError(w http.ResponseWriter, statusCode int, apiErr apiError) {
+	resBody, err := json.Marshal(apiErrorResponse{Error: apiErr})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(resBody)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/urlpolicy"
+)
+
+func TestHandlers_StoreURLPolicy(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	h.Policy = &urlpolicy.Policy{SelfHosts: []string{"example.com"}}
+
+	tests := []struct {
+		name       string
+		longURL    string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "should store allowed url",
+			longURL:    "https://example.org/page",
+			statusCode: http.StatusCreated,
+			response:   "https://example.com/1",
+		},
+		{
+			name:       "should reject javascript url",
+			longURL:    "javascript:alert(1)",
+			statusCode: http.StatusUnprocessableEntity,
+			response:   `{"error":{"code":"scheme_not_allowed","message":"scheme \"javascript\" is not allowed","url":"javascript:alert(1)"}}`,
+		},
+		{
+			name:       "should reject redirect loop",
+			longURL:    "https://example.com/1",
+			statusCode: http.StatusUnprocessableEntity,
+			response:   `{"error":{"code":"self_reference","message":"url must not point to the shortener itself","url":"https://example.com/1"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com", bytes.NewBufferString(tt.longURL))
+			w := httptest.NewRecorder()
+
+			h.StoreURL(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			assert.Equal(t, tt.response, w.Body.String())
+		})
+	}
+}
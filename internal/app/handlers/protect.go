@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	linkAccessCookiePrefix = "link_access_"
+	linkAccessTTL          = 15 * time.Minute
+)
+
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+</head>
+<body>
+<form method="post">
+<p>This link is password protected.</p>
+{{if .Failed}}<p>Wrong password.</p>{{end}}
+<input type="password" name="password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// UnlockURL checks the password posted from the form served by GetURL and grants
+// the client a short-lived access cookie for the link.
+func (h Handlers) UnlockURL(w http.ResponseWriter, r *http.Request) {
+	shortURL, err := h.Storage.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || shortURL.PasswordHash == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if shortURL.IsDeleted {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	password := r.PostFormValue("password")
+	if err := bcrypt.CompareHashAndPassword([]byte(shortURL.PasswordHash), []byte(password)); err != nil {
+		servePasswordForm(w, http.StatusUnauthorized, true)
+		return
+	}
+
+	expires := time.Now().Add(linkAccessTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkAccessCookiePrefix + shortURL.ID,
+		Value:    h.linkAccessToken(shortURL.ID, expires),
+		Path:     "/" + shortURL.ID,
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
+
+func (h Handlers) hasLinkAccess(r *http.Request, id string) bool {
+	c, err := r.Cookie(linkAccessCookiePrefix + id)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.SplitN(c.Value, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	expires := time.Unix(unix, 0)
+	if time.Now().After(expires) {
+		return false
+	}
+
+	return hmac.Equal([]byte(c.Value), []byte(h.linkAccessToken(id, expires)))
+}
+
+// linkAccessToken signs the link id with the expiration time, so the cookie can be
+// neither moved to another link nor prolonged.
+func (h Handlers) linkAccessToken(id string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(h.Secret))
+	mac.Write([]byte(id + "." + exp))
+
+	return exp + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func servePasswordForm(w http.ResponseWriter, status int, failed bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = passwordFormTemplate.Execute(w, struct{ Failed bool }{failed})
+}
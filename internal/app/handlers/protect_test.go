@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/ratelimit"
+)
+
+func TestRouter_PasswordProtectedURL(t *testing.T) {
+	h := getHandlers(nil)
+	h.Limiter = ratelimit.NewMemoryLimiter()
+	h.PasswordLimit = ratelimit.Limit{Requests: 2, Per: time.Minute}
+	r := NewRouter(h)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/doc","password":"secret"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/?password=secret", bytes.NewBufferString("https://example.com/other"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code, "password must not be accepted in the query string")
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `name="password"`)
+
+	unlock := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/1", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	accessCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == linkAccessCookiePrefix+"1" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	w = unlock("wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, accessCookie(w))
+
+	w = unlock("secret")
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	access := accessCookie(w)
+	require.NotNil(t, access)
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	req.AddCookie(access)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://example.com/doc", w.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	req.AddCookie(&http.Cookie{Name: access.Name, Value: strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + ".00"})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = unlock("secret")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRouter_PasswordRateLimit(t *testing.T) {
+	h := getHandlers(nil)
+	h.Limiter = ratelimit.NewMemoryLimiter()
+	h.PasswordLimit = ratelimit.Limit{Requests: 2, Per: time.Minute}
+	r := NewRouter(h)
+
+	for _, dest := range []string{"https://dest.example.org/a", "https://dest.example.org/b"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"`+dest+`","password":"secret"}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	// A client may collect many valid user cookies and rotate through them.
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/user/urls", nil))
+	require.NotEmpty(t, w.Result().Cookies())
+	userCookie := w.Result().Cookies()[0]
+
+	unlock := func(id string, headers map[string]string) int {
+		form := url.Values{"password": {"wrong"}}
+		req := httptest.NewRequest(http.MethodPost, "/"+id, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		headers    map[string]string
+		statusCode int
+	}{
+		{
+			name:       "first attempt",
+			id:         "1",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "second attempt",
+			id:         "1",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "limited attempt",
+			id:         "1",
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:       "api key header",
+			id:         "1",
+			headers:    map[string]string{"X-API-Key": "random"},
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:       "fresh user cookie",
+			id:         "1",
+			headers:    map[string]string{"Cookie": userCookie.String()},
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:       "spoofed forwarded address",
+			id:         "1",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.7"},
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:       "another link",
+			id:         "2",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.statusCode, unlock(tt.id, tt.headers))
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const (
+	publicStatsDays   = 30
+	publicChartHeight = 100
+	publicBarWidth    = 16
+)
+
+var publicStatsTemplate = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>{{.ShortURL}} stats</title>
+</head>
+<body>
+<h1>{{.ShortURL}}</h1>
+{{if .Destination}}<p>Destination: <a href="{{.Destination}}" rel="nofollow">{{.Destination}}</a></p>{{end}}
+<p>Created: {{.CreatedAt.Format "2006-01-02"}}</p>
+<p>Total clicks: {{.Clicks}}</p>
+<svg width="{{.ChartWidth}}" height="{{.ChartHeight}}" role="img" aria-label="Daily clicks">
+{{range .Bars}}<rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}" fill="#4a90d9"><title>{{.Day.Format "2006-01-02"}}: {{.Clicks}}</title></rect>
+{{end}}</svg>
+</body>
+</html>
+`))
+
+type publicStatsPage struct {
+	ShortURL    string
+	Destination string
+	CreatedAt   time.Time
+	Clicks      int
+	ChartWidth  int
+	ChartHeight int
+	Bars        []publicStatsBar
+}
+
+type publicStatsBar struct {
+	Day    time.Time
+	Clicks int
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+type apiSetPublicRequest struct {
+	Public bool `json:"public"`
+}
+
+type apiSetPublicResponse struct {
+	Public   bool   `json:"public"`
+	StatsURL string `json:"stats_url,omitempty"`
+}
+
+// PublicURLStats serves the summary page of links their owners made public,
+// destinations of protected, click-limited and inactive links stay hidden.
+func (h Handlers) PublicURLStats(w http.ResponseWriter, r *http.Request) {
+	shortURL, err := h.Storage.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || shortURL.IsDeleted || !shortURL.PublicStats {
+		http.NotFound(w, r)
+		return
+	}
+
+	counts, err := h.Storage.CountClicks(r.Context(), shortURL.ID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	to := storage.GranularityDay.Truncate(time.Now()).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -publicStatsDays)
+	rollups, err := h.Storage.FindURLRollups(r.Context(), shortURL.ID, storage.GranularityDay, from, to)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	page := publicStatsPage{
+		ShortURL:    h.BaseURL + "/" + shortURL.ID,
+		CreatedAt:   shortURL.CreatedAt,
+		ChartWidth:  publicStatsDays * publicBarWidth,
+		ChartHeight: publicChartHeight,
+		Bars:        dailyBars(rollups, from),
+	}
+	if shortURL.PasswordHash == "" && shortURL.MaxClicks == 0 && inActivationWindow(shortURL, time.Now()) {
+		page.Destination = shortURL.LongURL
+	}
+	for _, c := range counts {
+		if !c.Bot {
+			page.Clicks += c.Clicks
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = publicStatsTemplate.Execute(w, page)
+}
+
+func (h Handlers) APISetURLPublic(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiSetPublicRequest
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, ok := h.userURL(w, r, storage.Role.CanEdit)
+	if !ok {
+		return
+	}
+
+	shortURL, err = h.Storage.SetPublicStats(r.Context(), shortURL.ID, reqData.Public)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := apiSetPublicResponse{Public: shortURL.PublicStats}
+	if shortURL.PublicStats {
+		resData.StatsURL = h.BaseURL + "/" + shortURL.ID + "+"
+	}
+	writeJSON(w, resData)
+}
+
+// dailyBars lays out one bar per day starting at from, scaled to the busiest day.
+func dailyBars(rollups []storage.Rollup, from time.Time) []publicStatsBar {
+	clicks := make(map[int64]int)
+	peak := 0
+	for _, r := range rollups {
+		clicks[r.Bucket.Unix()] = r.Clicks
+		if r.Clicks > peak {
+			peak = r.Clicks
+		}
+	}
+
+	bars := make([]publicStatsBar, publicStatsDays)
+	for i := range bars {
+		day := from.AddDate(0, 0, i)
+		bar := publicStatsBar{
+			Day:    day,
+			Clicks: clicks[day.Unix()],
+			X:      i * publicBarWidth,
+			Width:  publicBarWidth - 2,
+		}
+		if peak > 0 {
+			bar.Height = bar.Clicks * publicChartHeight / peak
+		}
+		bar.Y = publicChartHeight - bar.Height
+		bars[i] = bar
+	}
+
+	return bars
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestRouter_PublicURLStats(t *testing.T) {
+	h := getHandlers(nil)
+	ctx := context.Background()
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	_, err := h.Storage.Create(ctx, storage.ShortURL{ID: "pub", LongURL: "https://dest.example.org/pub", UserID: userID})
+	require.NoError(t, err)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "lim", LongURL: "https://dest.example.org/lim", UserID: userID, MaxClicks: 5, ClicksLeft: 5})
+	require.NoError(t, err)
+	launch := time.Now().Add(time.Hour)
+	_, err = h.Storage.Create(ctx, storage.ShortURL{ID: "soon", LongURL: "https://dest.example.org/soon", UserID: userID, NotBefore: &launch})
+	require.NoError(t, err)
+	for _, c := range []storage.Click{
+		{URLID: "pub", CreatedAt: time.Now()},
+		{URLID: "pub", CreatedAt: time.Now()},
+		{URLID: "pub", CreatedAt: time.Now(), Bot: true},
+	} {
+		require.NoError(t, h.Storage.RecordClick(ctx, c))
+	}
+	require.NoError(t, h.Storage.RollupClicks(ctx, time.Time{}))
+	r := NewRouter(h)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	setPublic := func(id string, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/user/urls/"+id+"/public", strings.NewReader(`{"public":true}`))
+		req = withURLParams(withUser(req, userID), map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		h.APISetURLPublic(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/pub+").Code)
+
+	w := setPublic("pub", "5d1b1bbc-6d5e-4c08-8c8e-5d0d3b3f2a77")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = setPublic("pub", userID)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"public":true,"stats_url":"https://example.com/pub+"}`, w.Body.String())
+	require.Equal(t, http.StatusOK, setPublic("lim", userID).Code)
+	require.Equal(t, http.StatusOK, setPublic("soon", userID).Code)
+
+	w = get("/pub+")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Total clicks: 2")
+	assert.Contains(t, w.Body.String(), "https://dest.example.org/pub")
+
+	w = get("/lim+")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "https://dest.example.org/lim")
+
+	w = get("/soon+")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "https://dest.example.org/soon")
+
+	w = get("/pub")
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://dest.example.org/pub", w.Header().Get("Location"))
+}
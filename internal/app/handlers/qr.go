@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"image/color"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/virp/go-shortener/internal/app/qr"
+)
+
+const (
+	qrMinSize   = 32
+	qrMaxSize   = 2048
+	qrMaxMargin = 16
+)
+
+var errInvalidQROptions = errors.New("invalid qr options")
+
+// GetQRCode renders a QR code of the short link, anyone may request it
+// since it only encodes the public short URL.
+func (h Handlers) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	shortURL, err := h.Storage.GetByID(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || shortURL.IsDeleted {
+		http.NotFound(w, r)
+		return
+	}
+
+	opts, err := qrOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	content := h.BaseURL + "/" + shortURL.ID
+	var data []byte
+	if h.QRCache != nil {
+		data, err = h.QRCache.Render(content, opts)
+	} else {
+		data, err = qr.Render(content, opts)
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	_, _ = w.Write(data)
+}
+
+func qrOptionsFromQuery(q url.Values) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	if f := q.Get("format"); f != "" {
+		if f != qr.FormatPNG && f != qr.FormatSVG {
+			return qr.Options{}, errInvalidQROptions
+		}
+		opts.Format = f
+	}
+	if s := q.Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < qrMinSize || size > qrMaxSize {
+			return qr.Options{}, errInvalidQROptions
+		}
+		opts.Size = size
+	}
+	if l := q.Get("level"); l != "" {
+		if !qr.ValidLevel(l) {
+			return qr.Options{}, errInvalidQROptions
+		}
+		opts.Level = l
+	}
+	if m := q.Get("margin"); m != "" {
+		margin, err := strconv.Atoi(m)
+		if err != nil || margin < 0 || margin > qrMaxMargin {
+			return qr.Options{}, errInvalidQROptions
+		}
+		opts.Margin = margin
+	}
+	for param, c := range map[string]*color.RGBA{"fg": &opts.Foreground, "bg": &opts.Background} {
+		if v := q.Get(param); v != "" {
+			parsed, err := qr.ParseColor(v)
+			if err != nil {
+				return qr.Options{}, errInvalidQROptions
+			}
+			*c = parsed
+		}
+	}
+
+	return opts, nil
+}
+
+func (h Handlers) qrURL(id string) string {
+	return h.BaseURL + "/api/qr/" + id
+}
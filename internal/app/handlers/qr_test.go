@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/qr"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestRouter_GetQRCode(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{{ID: "1", LongURL: "https://dest.example.org/"}})
+	h.QRCache = qr.NewCache(10)
+	r := NewRouter(h)
+
+	tests := []struct {
+		name        string
+		path        string
+		statusCode  int
+		contentType string
+	}{
+		{
+			name:        "default png",
+			path:        "/api/qr/1",
+			statusCode:  http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "svg with options",
+			path:        "/api/qr/1?format=svg&size=512&level=H&margin=2&fg=%23003366&bg=ffffff",
+			statusCode:  http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:       "unknown link",
+			path:       "/api/qr/2",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "invalid size",
+			path:       "/api/qr/1?size=10000",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid color",
+			path:       "/api/qr/1?fg=blue",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid level",
+			path:       "/api/qr/1?level=Z",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/qr/1?size=300", nil))
+	img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+}
+
+func TestHandlers_APIStoreURLWithQR(t *testing.T) {
+	r := NewRouter(getHandlers(nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://dest.example.org/a","qr":true}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"result":"https://example.com/1","qr":"https://example.com/api/qr/1"}`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBufferString(`[
+		{"correlation_id":"a","original_url":"https://dest.example.org/b","qr":true},
+		{"correlation_id":"b","original_url":"https://dest.example.org/c"}
+	]`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `[
+		{"correlation_id":"a","short_url":"https://example.com/2","qr":"https://example.com/api/qr/2"},
+		{"correlation_id":"b","short_url":"https://example.com/3"}
+	]`, w.Body.String())
+}
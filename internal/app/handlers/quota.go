@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+// Quota caps links created by one user, zero values mean no limit.
+type Quota struct {
+	TotalLinks int
+	DailyLinks int
+	BatchSize  int
+}
+
+// ParseQuota parses quota in form "total=100,daily=20,batch=10", omitted keys are unlimited.
+func ParseQuota(s string) (Quota, error) {
+	var q Quota
+	if s == "" {
+		return q, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return Quota{}, fmt.Errorf("quota %q: expected key=value pairs", s)
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < 0 {
+			return Quota{}, fmt.Errorf("quota %q: invalid %s value", s, kv[0])
+		}
+		switch kv[0] {
+		case "total":
+			q.TotalLinks = n
+		case "daily":
+			q.DailyLinks = n
+		case "batch":
+			q.BatchSize = n
+		default:
+			return Quota{}, fmt.Errorf("quota %q: unknown key %s", s, kv[0])
+		}
+	}
+
+	return q, nil
+}
+
+type apiQuotaCounter struct {
+	Limit     int        `json:"limit"`
+	Used      int        `json:"used"`
+	Remaining *int       `json:"remaining,omitempty"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+}
+
+type apiQuotaResponse struct {
+	Registered bool            `json:"registered"`
+	Total      apiQuotaCounter `json:"total"`
+	Daily      apiQuotaCounter `json:"daily"`
+	BatchSize  int             `json:"batch_size"`
+}
+
+var errBatchQuotaExceeded = errors.New("batch size quota exceeded")
+
+func (h Handlers) APIGetUserQuota(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromRequest(r)
+	quota, registered, err := h.userQuota(r, userID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	dayStart := startOfDay(time.Now())
+	counts, err := h.Storage.CountUserURLs(r.Context(), userID, dayStart)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resetAt := dayStart.Add(24 * time.Hour)
+	response := apiQuotaResponse{
+		Registered: registered,
+		Total:      newQuotaCounter(quota.TotalLinks, counts.Active),
+		Daily:      newQuotaCounter(quota.DailyLinks, counts.CreatedSince),
+		BatchSize:  quota.BatchSize,
+	}
+	if quota.DailyLinks > 0 {
+		response.Daily.ResetAt = &resetAt
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+// linkQuota returns the quota storage enforces when userID creates n links, it writes
+// an error response and returns false when the batch is too large.
+func (h Handlers) linkQuota(w http.ResponseWriter, r *http.Request, userID string, n int) (storage.Quota, bool) {
+	if h.AnonymousQuota == (Quota{}) && h.UserQuota == (Quota{}) {
+		return storage.Quota{}, true
+	}
+
+	quota, _, err := h.userQuota(r, userID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return storage.Quota{}, false
+	}
+	if quota.BatchSize > 0 && n > quota.BatchSize {
+		http.Error(w, errBatchQuotaExceeded.Error(), http.StatusForbidden)
+		return storage.Quota{}, false
+	}
+
+	return storage.Quota{
+		Total:    quota.TotalLinks,
+		Daily:    quota.DailyLinks,
+		DayStart: startOfDay(time.Now()),
+	}, true
+}
+
+// createURLs creates links within quota, it writes an error response and returns false on failure.
+func (h Handlers) createURLs(w http.ResponseWriter, r *http.Request, urls []storage.ShortURL, quota storage.Quota) ([]storage.ShortURL, bool) {
+	created, err := h.Storage.CreateBatch(r.Context(), urls, quota)
+	if err != nil {
+		if errors.Is(err, storage.ErrTotalQuota) || errors.Is(err, storage.ErrDailyQuota) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return nil, false
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+	// an existing link must never stand in for one with options it lacks
+	for i, shortURL := range created {
+		if !shortURL.Inserted && urls[i].HasOptions() {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return nil, false
+		}
+	}
+
+	return created, true
+}
+
+func (h Handlers) userQuota(r *http.Request, userID string) (Quota, bool, error) {
+	_, err := h.Storage.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return h.AnonymousQuota, false, nil
+		}
+		return Quota{}, false, err
+	}
+
+	return h.UserQuota, true, nil
+}
+
+func newQuotaCounter(limit, used int) apiQuotaCounter {
+	counter := apiQuotaCounter{Limit: limit, Used: used}
+	if limit > 0 {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		counter.Remaining = &remaining
+	}
+
+	return counter
+}
+
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestParseQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Quota
+		wantErr bool
+	}{
+		{
+			name:  "unlimited",
+			value: "",
+			want:  Quota{},
+		},
+		{
+			name:  "all limits",
+			value: "total=100, daily=20,batch=10",
+			want:  Quota{TotalLinks: 100, DailyLinks: 20, BatchSize: 10},
+		},
+		{
+			name:    "unknown key",
+			value:   "monthly=10",
+			wantErr: true,
+		},
+		{
+			name:    "negative value",
+			value:   "total=-1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuota(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, q)
+		})
+	}
+}
+
+func TestHandlers_Quota(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	h.AnonymousQuota = Quota{TotalLinks: 3, DailyLinks: 10, BatchSize: 2}
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "batch within quota",
+			body:       `[{"correlation_id":"1","original_url":"https://example.com/1"},{"correlation_id":"2","original_url":"https://example.com/2"}]`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "batch too large",
+			body:       `[{"correlation_id":"1","original_url":"https://example.com/1"},{"correlation_id":"2","original_url":"https://example.com/2"},{"correlation_id":"3","original_url":"https://example.com/3"}]`,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "batch exceeds total links",
+			body:       `[{"correlation_id":"3","original_url":"https://example.com/3"},{"correlation_id":"4","original_url":"https://example.com/4"}]`,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "last link within total links",
+			body:       `[{"correlation_id":"3","original_url":"https://example.com/3"}]`,
+			statusCode: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(
+				http.MethodPost,
+				"https://example.com/api/shorten/batch",
+				bytes.NewBufferString(tt.body),
+			), "anonymous")
+			w := httptest.NewRecorder()
+
+			h.APIStoreURLBatch(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+
+	// Duplicates are found before the quota, they are not new links.
+	req := withUser(httptest.NewRequest(http.MethodPost, "https://example.com/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/1"}`)), "anonymous")
+	w := httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req = withUser(httptest.NewRequest(http.MethodGet, "https://example.com/api/user/quota", nil), "anonymous")
+	w = httptest.NewRecorder()
+	h.APIGetUserQuota(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var quota apiQuotaResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quota))
+	assert.False(t, quota.Registered)
+	assert.Equal(t, 3, quota.Total.Used)
+	require.NotNil(t, quota.Total.Remaining)
+	assert.Equal(t, 0, *quota.Total.Remaining)
+	assert.Equal(t, 3, quota.Daily.Used)
+	assert.NotNil(t, quota.Daily.ResetAt)
+	assert.Equal(t, 2, quota.BatchSize)
+}
+
+func TestHandlers_QuotaConcurrent(t *testing.T) {
+	h := getHandlers(nil)
+	h.AnonymousQuota = Quota{TotalLinks: 5}
+
+	codes := make(chan int, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"url":"https://dest.example.org/%d"}`, i)
+			req := withUser(httptest.NewRequest(http.MethodPost, "https://example.com/api/shorten", bytes.NewBufferString(body)), "anonymous")
+			w := httptest.NewRecorder()
+			h.APIStoreURL(w, req)
+			codes <- w.Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		if code == http.StatusCreated {
+			created++
+			continue
+		}
+		assert.Equal(t, http.StatusForbidden, code)
+	}
+	assert.Equal(t, 5, created)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/urlnorm"
+)
+
+type apiUpdateURLRequest struct {
+	URL string `json:"url"`
+}
+
+type apiRevertURLRequest struct {
+	RevisionID int `json:"revision_id"`
+}
+
+type apiRevision struct {
+	ID          int       `json:"id"`
+	OriginalURL string    `json:"original_url"`
+	EditorID    string    `json:"editor_id"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+}
+
+type apiURLHistory struct {
+	ShortURL    string        `json:"short_url"`
+	OriginalURL string        `json:"original_url"`
+	Revisions   []apiRevision `json:"revisions"`
+}
+
+func (h Handlers) APIUpdateUserURL(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiUpdateURLRequest
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.ParseRequestURI(reqData.URL)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, ok := h.userURL(w, r, storage.Role.CanEdit)
+	if !ok {
+		return
+	}
+
+	h.updateTarget(w, r, shortURL, u)
+}
+
+func (h Handlers) APIRevertUserURL(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiRevertURLRequest
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, ok := h.userURL(w, r, storage.Role.CanEdit)
+	if !ok {
+		return
+	}
+
+	rev, err := h.Storage.GetRevision(r.Context(), shortURL.ID, reqData.RevisionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	u, err := url.Parse(rev.LongURL)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h.updateTarget(w, r, shortURL, u)
+}
+
+func (h Handlers) APIGetURLHistory(w http.ResponseWriter, r *http.Request) {
+	shortURL, ok := h.userURL(w, r, isOrgMember)
+	if !ok {
+		return
+	}
+
+	revisions := h.Storage.FindRevisions(r.Context(), shortURL.ID)
+	response := apiURLHistory{
+		ShortURL:    fmt.Sprintf("%s/%s", h.BaseURL, shortURL.ID),
+		OriginalURL: shortURL.LongURL,
+		Revisions:   make([]apiRevision, len(revisions)),
+	}
+	for i, rev := range revisions {
+		response.Revisions[i] = apiRevision{
+			ID:          rev.ID,
+			OriginalURL: rev.LongURL,
+			EditorID:    rev.EditorID,
+			ReplacedAt:  rev.CreatedAt,
+		}
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+// updateTarget points shortURL to u, the policy is checked again as reverted
+// targets may have been denied since they were set.
+func (h Handlers) updateTarget(w http.ResponseWriter, r *http.Request, shortURL storage.ShortURL, u *url.URL) {
+	if shortURL.IsDeleted {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if !h.checkURLPolicy(w, r, u) {
+		return
+	}
+
+	canonicalURL, err := urlnorm.Canonicalize(u, h.Normalization)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL.LongURL = u.String()
+	shortURL.CanonicalURL = canonicalURL
+	shortURL, err = h.Storage.UpdateTarget(r.Context(), shortURL, getUserIDFromRequest(r))
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExist) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	h.fetchMetadata(shortURL)
+
+	resBody, err := json.Marshal(h.newAPIUserURL(shortURL, time.Now()))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/urlpolicy"
+)
+
+func TestHandlers_APIUpdateUserURL(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	ctx := context.Background()
+	for _, u := range []storage.ShortURL{
+		{ID: "1", LongURL: "https://example.com/typo", UserID: "owner"},
+		{ID: "2", LongURL: "https://example.com/taken", CanonicalURL: "https://example.com/taken", UserID: "owner"},
+	} {
+		_, err := h.Storage.Create(ctx, u)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name       string
+		userID     string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "should reject other users",
+			userID:     "other",
+			body:       `{"url":"https://example.com/fixed"}`,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "should reject invalid url",
+			userID:     "owner",
+			body:       `{"url":"not a url"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject target of another link",
+			userID:     "owner",
+			body:       `{"url":"https://example.com/taken"}`,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "should update target",
+			userID:     "owner",
+			body:       `{"url":"https://example.com/fixed"}`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(
+				http.MethodPatch,
+				"https://example.com/api/user/urls/1",
+				bytes.NewBufferString(tt.body),
+			), tt.userID)
+			req = withURLParams(req, map[string]string{"id": "1"})
+			w := httptest.NewRecorder()
+
+			h.APIUpdateUserURL(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+
+	shortURL, err := h.Storage.GetByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/fixed", shortURL.LongURL)
+
+	req := withURLParams(withUser(httptest.NewRequest(http.MethodGet, "https://example.com/", nil), "owner"), map[string]string{"id": "1"})
+	w := httptest.NewRecorder()
+	h.APIGetURLHistory(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var history apiURLHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Equal(t, "https://example.com/fixed", history.OriginalURL)
+	require.Len(t, history.Revisions, 1)
+	assert.Equal(t, "https://example.com/typo", history.Revisions[0].OriginalURL)
+	assert.Equal(t, "owner", history.Revisions[0].EditorID)
+
+	req = withURLParams(withUser(httptest.NewRequest(
+		http.MethodPost,
+		"https://example.com/",
+		bytes.NewBufferString(`{"revision_id":`+strconv.Itoa(history.Revisions[0].ID)+`}`),
+	), "owner"), map[string]string{"id": "1"})
+	w = httptest.NewRecorder()
+	h.APIRevertUserURL(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	shortURL, err = h.Storage.GetByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/typo", shortURL.LongURL)
+	revisions := h.Storage.FindRevisions(ctx, "1")
+	require.Len(t, revisions, 2)
+
+	// Targets denied after they were replaced cannot be restored.
+	h.Policy = &urlpolicy.Policy{DeniedDomains: []string{"example.com"}}
+	for _, rev := range revisions {
+		req = withURLParams(withUser(httptest.NewRequest(
+			http.MethodPost,
+			"https://example.com/",
+			bytes.NewBufferString(`{"revision_id":`+strconv.Itoa(rev.ID)+`}`),
+		), "owner"), map[string]string{"id": "1"})
+		w = httptest.NewRecorder()
+		h.APIRevertUserURL(w, req)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	}
+
+	shortURL, err = h.Storage.GetByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/typo", shortURL.LongURL)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+	PlatformOther   = "other"
+)
+
+const ruleTimeLayout = "15:04"
+
+var errInvalidRule = errors.New("invalid redirect rule")
+
+type apiRedirectRule struct {
+	Platform string `json:"platform,omitempty"`
+	Language string `json:"language,omitempty"`
+	Country  string `json:"country,omitempty"`
+	TimeFrom string `json:"time_from,omitempty"`
+	TimeTo   string `json:"time_to,omitempty"`
+	URL      string `json:"url"`
+}
+
+func (h Handlers) APISetURLRules(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData []apiRedirectRule
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, ok := h.userURL(w, r, storage.Role.CanEdit)
+	if !ok {
+		return
+	}
+
+	rules, err := h.redirectRules(r, reqData)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, err = h.Storage.SetRules(r.Context(), shortURL.ID, rules)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resBody, err := json.Marshal(newAPIRedirectRules(shortURL.Rules))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) redirectRules(r *http.Request, apiRules []apiRedirectRule) (storage.RedirectRules, error) {
+	var rules storage.RedirectRules
+	for _, ar := range apiRules {
+		rule := storage.RedirectRule{
+			Platform: strings.ToLower(ar.Platform),
+			Language: strings.ToLower(ar.Language),
+			Country:  strings.ToUpper(ar.Country),
+			TimeFrom: ar.TimeFrom,
+			TimeTo:   ar.TimeTo,
+			URL:      ar.URL,
+		}
+
+		switch rule.Platform {
+		case "", PlatformIOS, PlatformAndroid, PlatformWindows, PlatformMacOS, PlatformLinux, PlatformOther:
+		default:
+			return nil, fmt.Errorf("%w: unknown platform %q", errInvalidRule, ar.Platform)
+		}
+		if (rule.TimeFrom == "") != (rule.TimeTo == "") {
+			return nil, fmt.Errorf("%w: time range needs both bounds", errInvalidRule)
+		}
+		for _, t := range []string{rule.TimeFrom, rule.TimeTo} {
+			if _, err := time.Parse(ruleTimeLayout, t); t != "" && err != nil {
+				return nil, fmt.Errorf("%w: invalid time %q", errInvalidRule, t)
+			}
+		}
+
+		u, err := url.ParseRequestURI(rule.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidRule, err)
+		}
+		if h.Policy != nil {
+			if err := h.Policy.Check(r.Context(), u); err != nil {
+				return nil, fmt.Errorf("%w: %v", errInvalidRule, err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ruleTarget returns the URL of the first rule matching the request.
+func ruleTarget(r *http.Request, shortURL storage.ShortURL, country string, now time.Time) (string, bool) {
+	if len(shortURL.Rules) == 0 {
+		return "", false
+	}
+
+	platform := requestPlatform(r.UserAgent())
+	language := requestLanguage(r.Header.Get("Accept-Language"))
+	clock := now.UTC().Format(ruleTimeLayout)
+
+	for _, rule := range shortURL.Rules {
+		if rule.Platform != "" && rule.Platform != platform {
+			continue
+		}
+		if rule.Language != "" && language != rule.Language && !strings.HasPrefix(language, rule.Language+"-") {
+			continue
+		}
+		if rule.Country != "" && rule.Country != country {
+			continue
+		}
+		if rule.TimeFrom != "" && !inTimeRange(clock, rule.TimeFrom, rule.TimeTo) {
+			continue
+		}
+		return rule.URL, true
+	}
+
+	return "", false
+}
+
+// inTimeRange compares "15:04" formatted times, to before from wraps midnight.
+func inTimeRange(clock, from, to string) bool {
+	if from <= to {
+		return clock >= from && clock < to
+	}
+
+	return clock >= from || clock < to
+}
+
+func requestPlatform(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return PlatformIOS
+	case strings.Contains(userAgent, "Android"):
+		return PlatformAndroid
+	case strings.Contains(userAgent, "Windows"):
+		return PlatformWindows
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return PlatformMacOS
+	case strings.Contains(userAgent, "Linux"), strings.Contains(userAgent, "X11"):
+		return PlatformLinux
+	}
+
+	return PlatformOther
+}
+
+// requestLanguage returns the most preferred language tag of Accept-Language in lower case.
+func requestLanguage(header string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(p[2:], 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	return tags[0].name
+}
+
+func newAPIRedirectRules(rules storage.RedirectRules) []apiRedirectRule {
+	apiRules := make([]apiRedirectRule, len(rules))
+	for i, rule := range rules {
+		apiRules[i] = apiRedirectRule{
+			Platform: rule.Platform,
+			Language: rule.Language,
+			Country:  rule.Country,
+			TimeFrom: rule.TimeFrom,
+			TimeTo:   rule.TimeTo,
+			URL:      rule.URL,
+		}
+	}
+
+	return apiRules
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15"
+	androidUA = "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+)
+
+func TestHandlers_RuleTarget(t *testing.T) {
+	// httptest requests come from 192.0.2.1.
+	_, proxies, err := net.ParseCIDR("192.0.2.0/24")
+	require.NoError(t, err)
+	h := Handlers{CountryHeader: "CF-IPCountry", TrustedProxies: []*net.IPNet{proxies}}
+	shortURL := storage.ShortURL{
+		LongURL: "https://example.com/",
+		Rules: storage.RedirectRules{
+			{Platform: PlatformIOS, URL: "https://apps.apple.com/app"},
+			{Platform: PlatformAndroid, URL: "https://play.google.com/app"},
+			{Language: "de", URL: "https://example.com/de/"},
+			{Country: "FR", URL: "https://example.com/fr/"},
+			{TimeFrom: "22:00", TimeTo: "06:00", URL: "https://example.com/night"},
+		},
+	}
+	noon := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		now     time.Time
+		want    string
+	}{
+		{
+			name:    "ios",
+			headers: map[string]string{"User-Agent": iPhoneUA, "Accept-Language": "de-DE"},
+			now:     noon,
+			want:    "https://apps.apple.com/app",
+		},
+		{
+			name:    "android",
+			headers: map[string]string{"User-Agent": androidUA},
+			now:     noon,
+			want:    "https://play.google.com/app",
+		},
+		{
+			name:    "preferred language",
+			headers: map[string]string{"User-Agent": windowsUA, "Accept-Language": "en;q=0.5, de-AT;q=0.9"},
+			now:     noon,
+			want:    "https://example.com/de/",
+		},
+		{
+			name:    "country header",
+			headers: map[string]string{"User-Agent": windowsUA, "CF-IPCountry": "fr"},
+			now:     noon,
+			want:    "https://example.com/fr/",
+		},
+		{
+			name:    "time range over midnight",
+			headers: map[string]string{"User-Agent": windowsUA},
+			now:     time.Date(2022, 5, 1, 23, 30, 0, 0, time.UTC),
+			want:    "https://example.com/night",
+		},
+		{
+			name:    "fallback",
+			headers: map[string]string{"User-Agent": windowsUA, "Accept-Language": "en-US"},
+			now:     noon,
+			want:    "https://example.com/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/1", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			got, ok := ruleTarget(req, shortURL, h.clientLocation(req).Country, tt.now)
+			if !ok {
+				got = shortURL.LongURL
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRouter_SetURLRules(t *testing.T) {
+	h := getHandlers(nil)
+	r := NewRouter(h)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/"}`)), userID)
+	w := httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	req = withUser(httptest.NewRequest(http.MethodPut, "/api/user/urls/1/rules", bytes.NewBufferString(`[{"platform":"bsd","url":"https://example.com/bsd"}]`)), userID)
+	w = httptest.NewRecorder()
+	h.APISetURLRules(w, withURLParams(req, map[string]string{"id": "1"}))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = withUser(httptest.NewRequest(http.MethodPut, "/api/user/urls/1/rules", bytes.NewBufferString(`[{"platform":"ios","url":"https://apps.apple.com/app"}]`)), userID)
+	w = httptest.NewRecorder()
+	h.APISetURLRules(w, withURLParams(req, map[string]string{"id": "1"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	req.Header.Set("User-Agent", iPhoneUA)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "https://apps.apple.com/app", w.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	req.Header.Set("User-Agent", windowsUA)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "https://example.com/", w.Header().Get("Location"))
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+type apiURLStats struct {
+	Clicks    int               `json:"clicks"`
+	BotClicks int               `json:"bot_clicks"`
+	Variants  []apiVariantStats `json:"variants,omitempty"`
+	Countries []apiCountryStats `json:"countries,omitempty"`
+	Series    []apiRollup       `json:"series,omitempty"`
+}
+
+type apiVariantStats struct {
+	apiVariant
+	Clicks int     `json:"clicks"`
+	Share  float64 `json:"share"`
+}
+
+type apiCountryStats struct {
+	Country string         `json:"country"`
+	Clicks  int            `json:"clicks"`
+	Cities  []apiCityStats `json:"cities,omitempty"`
+}
+
+type apiCityStats struct {
+	City   string `json:"city"`
+	Clicks int    `json:"clicks"`
+}
+
+func (h Handlers) APIGetURLStats(w http.ResponseWriter, r *http.Request) {
+	shortURL, ok := h.userURL(w, r, isOrgMember)
+	if !ok {
+		return
+	}
+
+	sq, err := parseStatsQuery(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if sq.Format == formatCSV && sq.Granularity == "" {
+		sq.Granularity = storage.GranularityDay
+	}
+
+	var rollups []storage.Rollup
+	if sq.Granularity != "" {
+		rollups, err = h.Storage.FindURLRollups(r.Context(), shortURL.ID, sq.Granularity, sq.From, sq.To)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	if sq.Format == formatCSV {
+		writeRollups(w, sq.Format, rollups)
+		return
+	}
+
+	counts, err := h.Storage.CountClicks(r.Context(), shortURL.ID)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	var stats apiURLStats
+	var human []storage.ClickCount
+	byVariant := make(map[string]int)
+	for _, c := range counts {
+		if c.Bot {
+			stats.BotClicks += c.Clicks
+			continue
+		}
+		human = append(human, c)
+		stats.Clicks += c.Clicks
+		byVariant[c.Variant] += c.Clicks
+	}
+	stats.Countries = countryStats(human)
+
+	variantClicks := 0
+	for _, v := range shortURL.Variants {
+		variantClicks += byVariant[v.Name]
+	}
+	for _, v := range shortURL.Variants {
+		vs := apiVariantStats{
+			apiVariant: apiVariant{Name: v.Name, URL: v.URL, Weight: v.Weight},
+			Clicks:     byVariant[v.Name],
+		}
+		if variantClicks > 0 {
+			vs.Share = float64(vs.Clicks) / float64(variantClicks)
+		}
+		stats.Variants = append(stats.Variants, vs)
+	}
+	if len(rollups) > 0 {
+		stats.Series = newAPIRollups(rollups)
+	}
+
+	writeJSON(w, stats)
+}
+
+// countryStats aggregates clicks by country and city, the most clicked first.
+// Clicks with unknown location are reported with empty country and city.
+func countryStats(counts []storage.ClickCount) []apiCountryStats {
+	var countries []apiCountryStats
+	index := make(map[string]int)
+	for _, c := range counts {
+		i, ok := index[c.Country]
+		if !ok {
+			i = len(countries)
+			index[c.Country] = i
+			countries = append(countries, apiCountryStats{Country: c.Country})
+		}
+		countries[i].Clicks += c.Clicks
+		countries[i].Cities = addCityClicks(countries[i].Cities, c.City, c.Clicks)
+	}
+
+	sort.SliceStable(countries, func(i, j int) bool { return countries[i].Clicks > countries[j].Clicks })
+	for _, country := range countries {
+		cities := country.Cities
+		sort.SliceStable(cities, func(i, j int) bool { return cities[i].Clicks > cities[j].Clicks })
+	}
+
+	return countries
+}
+
+func addCityClicks(cities []apiCityStats, city string, clicks int) []apiCityStats {
+	if city == "" {
+		return cities
+	}
+	for i := range cities {
+		if cities[i].City == city {
+			cities[i].Clicks += clicks
+			return cities
+		}
+	}
+
+	return append(cities, apiCityStats{City: city, Clicks: clicks})
+}
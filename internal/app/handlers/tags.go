@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const maxLabelName = 64
+
+type apiLabelRequest struct {
+	Name string `json:"name"`
+}
+
+type apiFolder struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiTag struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type apiTagURLsRequest struct {
+	URLs   []string `json:"urls"`
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+type apiMoveURLsRequest struct {
+	URLs     []string `json:"urls"`
+	FolderID string   `json:"folder_id"`
+}
+
+var errInvalidLabel = errors.New("invalid name")
+
+func newAPIFolder(folder storage.Folder) apiFolder {
+	return apiFolder{ID: folder.ID, Name: folder.Name, CreatedAt: folder.CreatedAt}
+}
+
+func newAPITags(tags []storage.Tag) []apiTag {
+	if len(tags) == 0 {
+		return nil
+	}
+	res := make([]apiTag, len(tags))
+	for i, t := range tags {
+		res[i] = apiTag{ID: t.ID, Name: t.Name}
+	}
+
+	return res
+}
+
+// readLabelName reads the name of a folder or a tag from the request body.
+func readLabelName(r *http.Request) (string, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiLabelRequest
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(reqData.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxLabelName {
+		return "", errInvalidLabel
+	}
+
+	return name, nil
+}
+
+// writeLabelError maps storage errors of folder and tag changes to responses.
+func writeLabelError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, storage.ErrNameTaken):
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func writeCreated(w http.ResponseWriter, v interface{}) {
+	resBody, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APICreateFolder(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromRequest(r)
+	if userID == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	name, err := readLabelName(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	folder, err := h.Storage.CreateFolder(r.Context(), storage.Folder{ID: uuid.NewString(), UserID: userID, Name: name})
+	if err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	writeCreated(w, newAPIFolder(folder))
+}
+
+func (h Handlers) APIGetFolders(w http.ResponseWriter, r *http.Request) {
+	folders, err := h.Storage.FindUserFolders(r.Context(), getUserIDFromRequest(r))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := make([]apiFolder, 0, len(folders))
+	for _, folder := range folders {
+		resData = append(resData, newAPIFolder(folder))
+	}
+
+	writeJSON(w, resData)
+}
+
+func (h Handlers) APIRenameFolder(w http.ResponseWriter, r *http.Request) {
+	name, err := readLabelName(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	folder, err := h.Storage.RenameFolder(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "id"), name)
+	if err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, newAPIFolder(folder))
+}
+
+// APIDeleteFolder deletes a folder of the current user, its links are kept.
+func (h Handlers) APIDeleteFolder(w http.ResponseWriter, r *http.Request) {
+	if err := h.Storage.DeleteFolder(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "id")); err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h Handlers) APICreateTag(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromRequest(r)
+	if userID == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	name, err := readLabelName(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.Storage.CreateTag(r.Context(), storage.Tag{ID: uuid.NewString(), UserID: userID, Name: name})
+	if err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	writeCreated(w, apiTag{ID: tag.ID, Name: tag.Name})
+}
+
+func (h Handlers) APIGetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.Storage.FindUserTags(r.Context(), getUserIDFromRequest(r))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := newAPITags(tags)
+	if resData == nil {
+		resData = []apiTag{}
+	}
+
+	writeJSON(w, resData)
+}
+
+func (h Handlers) APIRenameTag(w http.ResponseWriter, r *http.Request) {
+	name, err := readLabelName(r)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.Storage.RenameTag(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "id"), name)
+	if err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	writeJSON(w, apiTag{ID: tag.ID, Name: tag.Name})
+}
+
+// APIDeleteTag deletes a tag of the current user and removes it from links.
+func (h Handlers) APIDeleteTag(w http.ResponseWriter, r *http.Request) {
+	if err := h.Storage.DeleteTag(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "id")); err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APITagUserURLs adds and removes tags of many links at once.
+func (h Handlers) APITagUserURLs(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiTagURLsRequest
+	if err := json.Unmarshal(body, &reqData); err != nil || len(reqData.Add)+len(reqData.Remove) == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	userID := getUserIDFromRequest(r)
+	for _, id := range append(append([]string(nil), reqData.Add...), reqData.Remove...) {
+		tag, err := h.Storage.GetTag(r.Context(), id)
+		if err != nil || tag.UserID != userID {
+			writeLabelError(w, r, storage.ErrNotFound)
+			return
+		}
+	}
+	if _, status := h.checkEditableURLs(r, userID, reqData.URLs); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if err := h.Storage.TagURLs(r.Context(), reqData.URLs, reqData.Add, reqData.Remove); err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIMoveUserURLs puts many links into a folder, an empty folder_id takes them out of folders.
+func (h Handlers) APIMoveUserURLs(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiMoveURLsRequest
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	userID := getUserIDFromRequest(r)
+	if reqData.FolderID != "" {
+		folder, err := h.Storage.GetFolder(r.Context(), reqData.FolderID)
+		if err != nil || folder.UserID != userID {
+			writeLabelError(w, r, storage.ErrNotFound)
+			return
+		}
+	}
+	urls, status := h.checkEditableURLs(r, userID, reqData.URLs)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	// folders are personal, organization links are shared with members who can not see them
+	for _, shortURL := range urls {
+		if reqData.FolderID != "" && shortURL.OrgID != "" {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.Storage.MoveURLs(r.Context(), reqData.URLs, reqData.FolderID); err != nil {
+		writeLabelError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkEditableURLs returns links with ids or an error status unless userID may edit
+// all of them, so bulk changes are applied to all of them or to none.
+func (h Handlers) checkEditableURLs(r *http.Request, userID string, ids []string) ([]storage.ShortURL, int) {
+	if len(ids) == 0 {
+		return nil, http.StatusBadRequest
+	}
+	urls := make([]storage.ShortURL, 0, len(ids))
+	for _, id := range ids {
+		shortURL, err := h.Storage.GetByID(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, http.StatusNotFound
+			}
+			return nil, http.StatusInternalServerError
+		}
+		if status := h.checkURLAccess(r, shortURL, userID, storage.Role.CanEdit); status != 0 {
+			return nil, status
+		}
+		urls = append(urls, shortURL)
+	}
+
+	return urls, 0
+}
+
+// findUserURLs returns links of userID or orgID in the folder and with all tags given
+// by folder and tag query parameters, tags of the links are returned keyed by link id.
+func (h Handlers) findUserURLs(r *http.Request, userID, orgID string) ([]storage.ShortURL, map[string][]storage.Tag, error) {
+	q := r.URL.Query()
+	urls, err := h.Storage.FindURLs(r.Context(), storage.URLFilter{
+		UserID:   userID,
+		OrgID:    orgID,
+		FolderID: q.Get("folder"),
+		TagIDs:   q["tag"],
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]string, len(urls))
+	for i, shortURL := range urls {
+		ids[i] = shortURL.ID
+	}
+	tags, err := h.Storage.FindURLTags(r.Context(), userID, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return urls, tags, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestHandlers_TagsAndFolders(t *testing.T) {
+	h := getHandlers(nil)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+	otherID := "5d1b1bbc-6d5e-4c08-8c8e-5d0d3b3f2a77"
+	ctx := context.Background()
+
+	for _, u := range []storage.ShortURL{
+		{ID: "a", LongURL: "https://dest.example.org/a", UserID: userID},
+		{ID: "b", LongURL: "https://dest.example.org/b", UserID: userID},
+		{ID: "c", LongURL: "https://dest.example.org/c", UserID: otherID},
+		{ID: "d", LongURL: "https://dest.example.org/d", UserID: otherID, OrgID: "org"},
+	} {
+		_, err := h.Storage.Create(ctx, u)
+		require.NoError(t, err)
+	}
+	_, err := h.Storage.CreateOrg(ctx, storage.Org{ID: "org", Name: "Marketing"}, userID)
+	require.NoError(t, err)
+
+	create := func(path, body string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, withUser(httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)), userID))
+		return w
+	}
+
+	w := create("/api/folders", `{"name":" "}`, h.APICreateFolder)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = create("/api/folders", `{"name":"Work"}`, h.APICreateFolder)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var folder apiFolder
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &folder))
+	w = create("/api/folders", `{"name":"Work"}`, h.APICreateFolder)
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	var tags []apiTag
+	for _, name := range []string{"promo", "q3"} {
+		w = create("/api/tags", `{"name":"`+name+`"}`, h.APICreateTag)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var tag apiTag
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tag))
+		tags = append(tags, tag)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		handler    http.HandlerFunc
+		statusCode int
+	}{
+		{
+			name:       "tag foreign link",
+			body:       `{"urls":["a","c"],"add":["` + tags[0].ID + `"]}`,
+			handler:    h.APITagUserURLs,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "unknown tag",
+			body:       `{"urls":["a"],"add":["missing"]}`,
+			handler:    h.APITagUserURLs,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "no changes",
+			body:       `{"urls":["a"]}`,
+			handler:    h.APITagUserURLs,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "tag links",
+			body:       `{"urls":["a","b"],"add":["` + tags[0].ID + `","` + tags[1].ID + `"]}`,
+			handler:    h.APITagUserURLs,
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "untag link",
+			body:       `{"urls":["b"],"remove":["` + tags[1].ID + `"]}`,
+			handler:    h.APITagUserURLs,
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "move foreign link",
+			body:       `{"urls":["c"],"folder_id":"` + folder.ID + `"}`,
+			handler:    h.APIMoveUserURLs,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "move organization link",
+			body:       `{"urls":["a","d"],"folder_id":"` + folder.ID + `"}`,
+			handler:    h.APIMoveUserURLs,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "move link",
+			body:       `{"urls":["a"],"folder_id":"` + folder.ID + `"}`,
+			handler:    h.APIMoveUserURLs,
+			statusCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := create("/api/user/urls/tags", tt.body, tt.handler)
+			assert.Equal(t, tt.statusCode, w.Code)
+		})
+	}
+
+	list := func(query string) []apiUserURL {
+		w := httptest.NewRecorder()
+		h.APIGetUserURLs(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls"+query, nil), userID))
+		if w.Code == http.StatusNoContent {
+			return nil
+		}
+		require.Equal(t, http.StatusOK, w.Code)
+		var urls []apiUserURL
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &urls))
+		return urls
+	}
+
+	urls := list("?folder=" + folder.ID)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "https://example.com/a", urls[0].ShortURL)
+	assert.Equal(t, folder.ID, urls[0].FolderID)
+	assert.Equal(t, tags, urls[0].Tags)
+
+	assert.Len(t, list("?tag="+tags[0].ID), 2)
+	urls = list("?tag=" + tags[0].ID + "&tag=" + tags[1].ID)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "https://example.com/a", urls[0].ShortURL)
+
+	w = httptest.NewRecorder()
+	req := withURLParams(withUser(httptest.NewRequest(http.MethodDelete, "/api/tags/"+tags[0].ID, nil), otherID), map[string]string{"id": tags[0].ID})
+	h.APIDeleteTag(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req = withURLParams(withUser(httptest.NewRequest(http.MethodDelete, "/api/folders/"+folder.ID, nil), userID), map[string]string{"id": folder.ID})
+	h.APIDeleteFolder(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, list("?folder="+folder.ID))
+	assert.Len(t, list(""), 2)
+}
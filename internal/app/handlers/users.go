@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/virp/go-shortener/internal/app/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type apiCredentialsRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type apiUserResponse struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+}
+
+func (h Handlers) APIRegisterUser(w http.ResponseWriter, r *http.Request) {
+	creds, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	user := storage.User{
+		ID:           uuid.NewString(),
+		Login:        creds.Login,
+		PasswordHash: string(hash),
+	}
+	user, err = h.Storage.CreateUser(r.Context(), user)
+	if err != nil {
+		if errors.Is(err, storage.ErrLoginTaken) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	h.signIn(w, r, user)
+}
+
+func (h Handlers) APILoginUser(w http.ResponseWriter, r *http.Request) {
+	creds, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.Storage.GetUserByLogin(r.Context(), creds.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	h.signIn(w, r, user)
+}
+
+func (h Handlers) APILogoutUser(w http.ResponseWriter, r *http.Request) {
+	aesGCM, err := getAES(h.Secret)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if err := setUserCookie(w, aesGCM, uuid.NewString()); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// signIn moves links of the current anonymous user to the account
+// and switches the user cookie to the account ID.
+func (h Handlers) signIn(w http.ResponseWriter, r *http.Request, user storage.User) {
+	currentUserID := getUserIDFromRequest(r)
+	if currentUserID != "" && currentUserID != user.ID {
+		_, err := h.Storage.GetUserByID(r.Context(), currentUserID)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			if err := h.Storage.MergeUserURLs(r.Context(), currentUserID, user.ID); err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		case err != nil:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	aesGCM, err := getAES(h.Secret)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err := setUserCookie(w, aesGCM, user.ID); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resBody, err := json.Marshal(apiUserResponse{ID: user.ID, Login: user.Login})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func readCredentials(w http.ResponseWriter, r *http.Request) (apiCredentialsRequest, bool) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return apiCredentialsRequest{}, false
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var creds apiCredentialsRequest
+	if err := json.Unmarshal(body, &creds); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return apiCredentialsRequest{}, false
+	}
+	if creds.Login == "" || creds.Password == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return apiCredentialsRequest{}, false
+	}
+
+	return creds, true
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestHandlers_APIRegisterUser(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	h.Secret = "secret"
+	_, err := h.Storage.Create(context.Background(), storage.ShortURL{LongURL: "https://example.com/anonymous", UserID: "anonymous"})
+	require.NoError(t, err)
+
+	req := withUser(httptest.NewRequest(
+		http.MethodPost,
+		"https://example.com/api/user/register",
+		bytes.NewBufferString(`{"login":"alice","password":"secret"}`),
+	), "anonymous")
+	w := httptest.NewRecorder()
+
+	h.APIRegisterUser(w, req)
+	res := w.Result()
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, res.Cookies(), 1)
+
+	user, err := h.Storage.GetUserByLogin(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.Len(t, h.Storage.FindByUserID(context.Background(), user.ID), 1)
+	assert.Empty(t, h.Storage.FindByUserID(context.Background(), "anonymous"))
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"https://example.com/api/user/register",
+		bytes.NewBufferString(`{"login":"alice","password":"other"}`),
+	)
+	w = httptest.NewRecorder()
+
+	h.APIRegisterUser(w, req)
+	res = w.Result()
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+}
+
+func TestHandlers_APILoginUser(t *testing.T) {
+	h := getHandlers([]storage.ShortURL{})
+	h.Secret = "secret"
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"https://example.com/api/user/register",
+		bytes.NewBufferString(`{"login":"bob","password":"secret"}`),
+	)
+	w := httptest.NewRecorder()
+	h.APIRegisterUser(w, req)
+	require.NoError(t, w.Result().Body.Close())
+
+	_, err := h.Storage.Create(context.Background(), storage.ShortURL{LongURL: "https://example.com/anonymous", UserID: "anonymous"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "should reject wrong password",
+			body:       `{"login":"bob","password":"wrong"}`,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "should reject unknown login",
+			body:       `{"login":"carol","password":"secret"}`,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "should reject empty credentials",
+			body:       `{}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "should login and merge anonymous links",
+			body:       `{"login":"bob","password":"secret"}`,
+			statusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(
+				http.MethodPost,
+				"https://example.com/api/user/login",
+				bytes.NewBufferString(tt.body),
+			), "anonymous")
+			w := httptest.NewRecorder()
+
+			h.APILoginUser(w, req)
+			res := w.Result()
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+		})
+	}
+
+	user, err := h.Storage.GetUserByLogin(context.Background(), "bob")
+	require.NoError(t, err)
+	assert.Len(t, h.Storage.FindByUserID(context.Background(), user.ID), 1)
+}
+
+func withUser(r *http.Request, userID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userKey, userID))
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+type apiUTMTemplate struct {
+	Name     string `json:"name"`
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+}
+
+func (h Handlers) APIGetUTMTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := h.Storage.FindUTMTemplates(r.Context(), getUserIDFromRequest(r))
+
+	response := make([]apiUTMTemplate, len(templates))
+	for i, tmpl := range templates {
+		response[i] = newAPIUTMTemplate(tmpl)
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APIGetUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := h.Storage.GetUTMTemplate(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resBody, err := json.Marshal(newAPIUTMTemplate(tmpl))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APISaveUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiUTMTemplate
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tmpl := storage.UTMTemplate{
+		UserID:   getUserIDFromRequest(r),
+		Name:     chi.URLParam(r, "name"),
+		Source:   reqData.Source,
+		Medium:   reqData.Medium,
+		Campaign: reqData.Campaign,
+		Term:     reqData.Term,
+		Content:  reqData.Content,
+	}
+	if tmpl.Name == "" || len(utmValues(tmpl)) == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Storage.SaveUTMTemplate(r.Context(), tmpl); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resBody, err := json.Marshal(newAPIUTMTemplate(tmpl))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) APIDeleteUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	err := h.Storage.DeleteUTMTemplate(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "name"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withUTM appends parameters of the link UTM template to target. Parameters already
+// present in target win, a missing template leaves target untouched.
+func (h Handlers) withUTM(r *http.Request, shortURL storage.ShortURL, target string) (string, error) {
+	if shortURL.UTMTemplate == "" {
+		return target, nil
+	}
+
+	tmpl, err := h.Storage.GetUTMTemplate(r.Context(), shortURL.UserID, shortURL.UTMTemplate)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return target, nil
+		}
+		return "", err
+	}
+
+	return passthroughTarget(target, PassthroughKeep, "", utmValues(tmpl))
+}
+
+func utmValues(tmpl storage.UTMTemplate) url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"utm_source":   tmpl.Source,
+		"utm_medium":   tmpl.Medium,
+		"utm_campaign": tmpl.Campaign,
+		"utm_term":     tmpl.Term,
+		"utm_content":  tmpl.Content,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	return values
+}
+
+func newAPIUTMTemplate(tmpl storage.UTMTemplate) apiUTMTemplate {
+	return apiUTMTemplate{
+		Name:     tmpl.Name,
+		Source:   tmpl.Source,
+		Medium:   tmpl.Medium,
+		Campaign: tmpl.Campaign,
+		Term:     tmpl.Term,
+		Content:  tmpl.Content,
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestRouter_UTMTemplates(t *testing.T) {
+	h := getHandlers(nil)
+	r := NewRouter(h)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+
+	req := withUser(httptest.NewRequest(http.MethodPut, "/api/user/utm-templates/newsletter", bytes.NewBufferString(`{"utm_source":"news","utm_medium":"email"}`)), userID)
+	w := httptest.NewRecorder()
+	h.APISaveUTMTemplate(w, withURLParams(req, map[string]string{"name": "newsletter"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/a?utm_source=site","utm_template":"newsletter"}`)), userID)
+	w = httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://example.com/a?utm_medium=email&utm_source=site", w.Header().Get("Location"))
+
+	req = withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/b","utm_template":"missing"}`)), userID)
+	w = httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	err := h.Storage.DeleteUTMTemplate(context.Background(), userID, "newsletter")
+	require.NoError(t, err)
+	_, err = h.Storage.GetUTMTemplate(context.Background(), userID, "newsletter")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "https://example.com/a?utm_source=site", w.Header().Get("Location"))
+}
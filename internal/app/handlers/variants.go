@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const (
+	variantCookiePrefix = "variant_"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// variantRand is seeded since the global source is deterministic until seeded,
+// a rand.Rand is not safe for concurrent use.
+var (
+	variantRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	variantRandMu sync.Mutex
+)
+
+var errInvalidVariant = errors.New("invalid variant")
+
+type apiVariant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+func (h Handlers) APISetURLVariants(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData []apiVariant
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, ok := h.userURL(w, r, storage.Role.CanEdit)
+	if !ok {
+		return
+	}
+
+	variants, err := h.linkVariants(r, reqData)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	shortURL, err = h.Storage.SetVariants(r.Context(), shortURL.ID, variants)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]apiVariant, len(shortURL.Variants))
+	for i, v := range shortURL.Variants {
+		response[i] = apiVariant{Name: v.Name, URL: v.URL, Weight: v.Weight}
+	}
+
+	resBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(resBody)
+}
+
+func (h Handlers) linkVariants(r *http.Request, apiVariants []apiVariant) (storage.Variants, error) {
+	var variants storage.Variants
+	names := make(map[string]bool)
+	for _, av := range apiVariants {
+		if av.Name == "" || names[av.Name] {
+			return nil, fmt.Errorf("%w: name must be unique and not empty", errInvalidVariant)
+		}
+		names[av.Name] = true
+		if av.Weight <= 0 {
+			return nil, fmt.Errorf("%w: weight must be positive", errInvalidVariant)
+		}
+
+		u, err := url.ParseRequestURI(av.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidVariant, err)
+		}
+		if h.Policy != nil {
+			if err := h.Policy.Check(r.Context(), u); err != nil {
+				return nil, fmt.Errorf("%w: %v", errInvalidVariant, err)
+			}
+		}
+
+		variants = append(variants, storage.Variant{Name: av.Name, URL: av.URL, Weight: av.Weight})
+	}
+
+	return variants, nil
+}
+
+// resolveTarget picks the destination for the request: a matching rule wins, then a variant
+// of split links, and the link target otherwise. The variant name is empty unless picked.
+func (h Handlers) resolveTarget(w http.ResponseWriter, r *http.Request, shortURL storage.ShortURL, country string, now time.Time) (string, string) {
+	if target, ok := ruleTarget(r, shortURL, country, now); ok {
+		return target, ""
+	}
+	if len(shortURL.Variants) == 0 {
+		return shortURL.LongURL, ""
+	}
+
+	v := pickVariant(r, shortURL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookiePrefix + shortURL.ID,
+		Value:    v.Name,
+		Path:     "/" + shortURL.ID,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return v.URL, v.Name
+}
+
+// pickVariant keeps returning visitors on the variant from their cookie and
+// picks a weighted random one for new visitors.
+func pickVariant(r *http.Request, shortURL storage.ShortURL) storage.Variant {
+	if c, err := r.Cookie(variantCookiePrefix + shortURL.ID); err == nil {
+		for _, v := range shortURL.Variants {
+			if v.Name == c.Value {
+				return v
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range shortURL.Variants {
+		total += v.Weight
+	}
+	variantRandMu.Lock()
+	n := variantRand.Intn(total)
+	variantRandMu.Unlock()
+	for _, v := range shortURL.Variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+
+	return shortURL.Variants[len(shortURL.Variants)-1]
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestRouter_SplitURL(t *testing.T) {
+	h := getHandlers(nil)
+	r := NewRouter(h)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/","variants":[{"name":"a","url":"https://example.com/a","weight":0}]}`)), userID)
+	w := httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/","variants":[{"name":"a","url":"https://example.com/a","weight":1},{"name":"b","url":"https://example.com/b","weight":3}]}`)), userID)
+	w = httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	var sticky *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == variantCookiePrefix+"1" {
+			sticky = c
+		}
+	}
+	require.NotNil(t, sticky)
+	location := w.Header().Get("Location")
+	assert.Equal(t, "https://example.com/"+sticky.Value, location)
+
+	for i := 0; i < 5; i++ {
+		req = httptest.NewRequest(http.MethodGet, "/1", nil)
+		req.AddCookie(sticky)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, location, w.Header().Get("Location"))
+	}
+
+	req = withUser(httptest.NewRequest(http.MethodGet, "/api/user/urls/1/stats", nil), userID)
+	w = httptest.NewRecorder()
+	h.APIGetURLStats(w, withURLParams(req, map[string]string{"id": "1"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var stats apiURLStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 6, stats.Clicks)
+	require.Len(t, stats.Variants, 2)
+	for _, v := range stats.Variants {
+		if v.Name == sticky.Value {
+			assert.Equal(t, 6, v.Clicks)
+			assert.Equal(t, 1.0, v.Share)
+		} else {
+			assert.Equal(t, 0, v.Clicks)
+		}
+	}
+}
+
+func TestPickVariantWeights(t *testing.T) {
+	shortURL := storage.ShortURL{
+		ID: "1",
+		Variants: storage.Variants{
+			{Name: "rare", URL: "https://example.com/rare", Weight: 1},
+			{Name: "often", URL: "https://example.com/often", Weight: 3},
+		},
+	}
+
+	picked := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		picked[pickVariant(httptest.NewRequest(http.MethodGet, "/1", nil), shortURL).Name]++
+	}
+
+	assert.Equal(t, 1000, picked["rare"]+picked["often"])
+	assert.InDelta(t, 750, picked["often"], 100)
+}
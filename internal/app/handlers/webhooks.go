@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/webhooks"
+)
+
+type apiWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type apiWebhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type apiDelivery struct {
+	ID            string          `json:"id"`
+	Event         string          `json:"event"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatus    int             `json:"last_status,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+type apiWebhookLink struct {
+	ID          string `json:"id"`
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	OrgID       string `json:"org_id,omitempty"`
+}
+
+type apiWebhookClick struct {
+	apiWebhookLink
+	Variant   string    `json:"variant,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	City      string    `json:"city,omitempty"`
+	Bot       bool      `json:"bot"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+var errInvalidWebhook = errors.New("invalid webhook")
+
+func newAPIWebhook(hook storage.Webhook) apiWebhook {
+	events := hook.Events
+	if events == nil {
+		events = webhooks.Events
+	}
+
+	return apiWebhook{
+		ID:        hook.ID,
+		URL:       hook.URL,
+		Events:    events,
+		CreatedAt: hook.CreatedAt,
+	}
+}
+
+// APICreateWebhook registers a webhook of the current user, the secret
+// signing payloads is generated unless given and returned only here.
+func (h Handlers) APICreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromRequest(r)
+	if userID == "" {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func() { _ = r.Body.Close() }()
+
+	var reqData apiWebhookRequest
+	if err := json.Unmarshal(body, &reqData); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	hook, err := newWebhook(userID, reqData)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	hook, err = h.Storage.CreateWebhook(r.Context(), hook)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := newAPIWebhook(hook)
+	resData.Secret = hook.Secret
+	resBody, err := json.Marshal(resData)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(resBody)
+}
+
+func newWebhook(userID string, reqData apiWebhookRequest) (storage.Webhook, error) {
+	u, err := url.ParseRequestURI(reqData.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return storage.Webhook{}, errInvalidWebhook
+	}
+
+	var events storage.WebhookEvents
+	for _, e := range reqData.Events {
+		known := false
+		for _, k := range webhooks.Events {
+			known = known || e == k
+		}
+		if !known {
+			return storage.Webhook{}, errInvalidWebhook
+		}
+		events = append(events, e)
+	}
+
+	secret := reqData.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return storage.Webhook{}, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	return storage.Webhook{
+		ID:     uuid.NewString(),
+		UserID: userID,
+		URL:    u.String(),
+		Secret: secret,
+		Events: events,
+	}, nil
+}
+
+func (h Handlers) APIGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.Storage.FindUserWebhooks(r.Context(), getUserIDFromRequest(r))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := make([]apiWebhook, 0, len(hooks))
+	for _, hook := range hooks {
+		resData = append(resData, newAPIWebhook(hook))
+	}
+
+	writeJSON(w, resData)
+}
+
+func (h Handlers) APIDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	err := h.Storage.DeleteWebhook(r.Context(), getUserIDFromRequest(r), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIGetWebhookDeliveries lists deliveries of a webhook, the status query
+// parameter filters them, e.g. status=dead returns dead letters.
+func (h Handlers) APIGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, err := h.Storage.GetWebhook(r.Context(), chi.URLParam(r, "id"))
+	if err != nil || hook.UserID != getUserIDFromRequest(r) {
+		if err == nil || errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", storage.DeliveryPending, storage.DeliveryDelivered, storage.DeliveryDead:
+	default:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.Storage.FindDeliveries(r.Context(), hook.ID, status)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resData := make([]apiDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		rd := apiDelivery{
+			ID:          d.ID,
+			Event:       d.Event,
+			Status:      d.Status,
+			Attempts:    d.Attempts,
+			LastStatus:  d.LastStatus,
+			LastError:   d.LastError,
+			Payload:     json.RawMessage(d.Payload),
+			CreatedAt:   d.CreatedAt,
+			DeliveredAt: d.DeliveredAt,
+		}
+		if d.Status == storage.DeliveryPending {
+			next := d.NextAttemptAt
+			rd.NextAttemptAt = &next
+		}
+		resData = append(resData, rd)
+	}
+
+	writeJSON(w, resData)
+}
+
+func (h Handlers) newAPIWebhookLink(shortURL storage.ShortURL) apiWebhookLink {
+	return apiWebhookLink{
+		ID:          shortURL.ID,
+		ShortURL:    h.BaseURL + "/" + shortURL.ID,
+		OriginalURL: shortURL.LongURL,
+		OrgID:       shortURL.OrgID,
+	}
+}
+
+// emit queues event for webhooks of the link owner, losing an event
+// must not fail the request which caused it.
+func (h Handlers) emit(ctx context.Context, event string, shortURL storage.ShortURL, data interface{}) {
+	if h.Webhooks != nil {
+		_ = h.Webhooks.Emit(ctx, shortURL.UserID, event, data)
+	}
+}
+
+// enqueue is emit for hot paths such as redirects, the event is saved in the background.
+func (h Handlers) enqueue(event string, shortURL storage.ShortURL, data interface{}) {
+	if h.Webhooks != nil {
+		h.Webhooks.Enqueue(shortURL.UserID, event, data)
+	}
+}
+
+// linkCreated runs background work for a new link.
+func (h Handlers) linkCreated(ctx context.Context, shortURL storage.ShortURL) {
+	h.fetchMetadata(shortURL)
+	h.emit(ctx, webhooks.EventLinkCreated, shortURL, h.newAPIWebhookLink(shortURL))
+}
+
+// deletableURLs returns links among ids userID may delete, used to report
+// deletions since DeleteBatch does not tell which links it deleted.
+func (h Handlers) deletableURLs(r *http.Request, userID string, ids []string) []storage.ShortURL {
+	if h.Webhooks == nil {
+		return nil
+	}
+
+	var urls []storage.ShortURL
+	for _, id := range ids {
+		shortURL, err := h.Storage.GetByID(r.Context(), id)
+		if err != nil || shortURL.IsDeleted {
+			continue
+		}
+		if h.checkURLAccess(r, shortURL, userID, storage.Role.CanEdit) == 0 {
+			urls = append(urls, shortURL)
+		}
+	}
+
+	return urls
+}
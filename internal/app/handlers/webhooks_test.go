@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/webhooks"
+)
+
+func TestHandlers_Webhooks(t *testing.T) {
+	h := getHandlers(nil)
+	h.Webhooks = webhooks.NewDispatcher(h.Storage, http.DefaultClient)
+	userID := "2f4f6a3c-6b1e-4b56-9a53-8d8b8c1a0c11"
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "unknown event",
+			body:       `{"url":"https://crm.example.org/hook","events":["link.renamed"]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid url",
+			body:       `{"url":"ftp://crm.example.org/hook"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "created",
+			body:       `{"url":"https://crm.example.org/hook","events":["link.created","link.deleted"]}`,
+			statusCode: http.StatusCreated,
+		},
+	}
+
+	var hook apiWebhook
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(httptest.NewRequest(http.MethodPost, "/api/webhooks", bytes.NewBufferString(tt.body)), userID)
+			w := httptest.NewRecorder()
+			h.APICreateWebhook(w, req)
+
+			require.Equal(t, tt.statusCode, w.Code)
+			if w.Code == http.StatusCreated {
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &hook))
+			}
+		})
+	}
+	require.NotEmpty(t, hook.ID)
+	assert.Len(t, hook.Secret, 64)
+
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://dest.example.org/a"}`)), userID)
+	w := httptest.NewRecorder()
+	h.APIStoreURL(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	// Duplicates are not new links.
+	w = httptest.NewRecorder()
+	h.APIStoreURL(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://dest.example.org/a"}`)), userID))
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	getDeliveries := func(userID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/webhooks/"+hook.ID+"/deliveries"+query, nil)
+		req = withURLParams(withUser(req, userID), map[string]string{"id": hook.ID})
+		w := httptest.NewRecorder()
+		h.APIGetWebhookDeliveries(w, req)
+		return w
+	}
+
+	w = getDeliveries(userID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var deliveries []apiDelivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, webhooks.EventLinkCreated, deliveries[0].Event)
+	assert.Equal(t, "pending", deliveries[0].Status)
+	assert.Contains(t, string(deliveries[0].Payload), `"original_url":"https://dest.example.org/a"`)
+
+	w = getDeliveries(userID, "?status=dead")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, getDeliveries("5d1b1bbc-6d5e-4c08-8c8e-5d0d3b3f2a77", "").Code)
+
+	hooks, err := h.Storage.FindUserWebhooks(context.Background(), userID)
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	assert.Equal(t, "https://crm.example.org/hook", hooks[0].URL)
+}
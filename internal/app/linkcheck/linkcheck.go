@@ -0,0 +1,199 @@
+// Package linkcheck periodically requests link destinations to find broken ones.
+package linkcheck
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/webhooks"
+)
+
+const maxDrainSize = 64 << 10
+
+// Notification is the data of events emitted when a link becomes broken or recovers.
+type Notification struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	OrgID     string    `json:"org_id,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Emitter queues events for webhooks of a user, see webhooks.Dispatcher.
+type Emitter interface {
+	Emit(ctx context.Context, userID, event string, data interface{}) error
+}
+
+type Checker struct {
+	storage storage.LinkCheckStorage
+	client  *http.Client
+	// Concurrency limits hosts checked at once, links of one host are checked
+	// sequentially HostDelay apart.
+	Concurrency int
+	HostDelay   time.Duration
+	// Webhooks of link owners are notified of broken and recovered links if set.
+	Webhooks Emitter
+}
+
+// NewChecker returns a checker requesting destinations with client,
+// which should refuse internal addresses, see the safehttp package.
+func NewChecker(s storage.LinkCheckStorage, client *http.Client) *Checker {
+	return &Checker{
+		storage:     s,
+		client:      client,
+		Concurrency: 8,
+		HostDelay:   time.Second,
+	}
+}
+
+// Run checks all checkable links every interval until ctx is done.
+func (c *Checker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.CheckAll(ctx); err != nil {
+			log.Printf("check links: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CheckAll checks destinations of all checkable links once.
+func (c *Checker) CheckAll(ctx context.Context) error {
+	urls, err := c.storage.FindCheckableURLs(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var hosts []string
+	byHost := make(map[string][]storage.ShortURL)
+	for _, u := range urls {
+		host := u.LongURL
+		if parsed, err := url.Parse(u.LongURL); err == nil {
+			host = parsed.Hostname()
+		}
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], u)
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < c.Concurrency && i < len(hosts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range queue {
+				c.checkHost(ctx, byHost[host])
+			}
+		}()
+	}
+	for _, host := range hosts {
+		select {
+		case queue <- host:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// checkHost checks links sharing a host, requesting each destination once.
+func (c *Checker) checkHost(ctx context.Context, urls []storage.ShortURL) {
+	results := make(map[string]storage.LinkCheck)
+	for _, u := range urls {
+		check, ok := results[u.LongURL]
+		if !ok {
+			if len(results) > 0 {
+				select {
+				case <-time.After(c.HostDelay):
+				case <-ctx.Done():
+					return
+				}
+			}
+			check = c.check(ctx, u.LongURL)
+			results[u.LongURL] = check
+		}
+		check.URLID = u.ID
+
+		updated, err := c.storage.RecordLinkCheck(ctx, check)
+		if err != nil {
+			log.Printf("record link check of %s: %v", u.ID, err)
+			continue
+		}
+
+		switch {
+		case !u.Broken() && updated.Broken():
+			c.notify(ctx, webhooks.EventLinkBroken, updated, check)
+		case u.Broken() && !updated.Broken():
+			c.notify(ctx, webhooks.EventLinkRecovered, updated, check)
+		}
+	}
+}
+
+// check requests rawURL with HEAD, falling back to GET for servers
+// which do not answer HEAD properly.
+func (c *Checker) check(ctx context.Context, rawURL string) storage.LinkCheck {
+	status, err := c.request(ctx, http.MethodHead, rawURL)
+	if err != nil || status >= 400 {
+		status, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+
+	check := storage.LinkCheck{Status: status, CheckedAt: time.Now()}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	return check
+}
+
+func (c *Checker) request(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "go-shortener-linkcheck/1.0")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxDrainSize))
+	_ = res.Body.Close()
+
+	return res.StatusCode, nil
+}
+
+func (c *Checker) notify(ctx context.Context, event string, u storage.ShortURL, check storage.LinkCheck) {
+	if c.Webhooks == nil {
+		return
+	}
+
+	err := c.Webhooks.Emit(ctx, u.UserID, event, Notification{
+		ID:        u.ID,
+		URL:       u.LongURL,
+		OrgID:     u.OrgID,
+		Status:    check.Status,
+		Error:     check.Error,
+		CheckedAt: check.CheckedAt,
+	})
+	if err != nil {
+		log.Printf("emit %s of %s: %v", event, u.ID, err)
+	}
+}
@@ -0,0 +1,109 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/safehttp"
+	"github.com/virp/go-shortener/internal/app/storage"
+	"github.com/virp/go-shortener/internal/app/webhooks"
+)
+
+func TestChecker_CheckAll(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	dest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}
+	}))
+	defer dest.Close()
+
+	type event struct {
+		Event string       `json:"event"`
+		Data  Notification `json:"data"`
+	}
+	notifications := make(chan event, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		notifications <- e
+	}))
+	defer hook.Close()
+
+	ctx := context.Background()
+	s, err := storage.NewMemoryStorage()
+	require.NoError(t, err)
+	for _, u := range []storage.ShortURL{
+		{ID: "ok", LongURL: dest.URL + "/ok"},
+		{ID: "ok2", LongURL: dest.URL + "/ok", UserID: "user"},
+		{ID: "gone", LongURL: dest.URL + "/gone", UserID: "user"},
+		{ID: "no-head", LongURL: dest.URL + "/no-head"},
+		{ID: "deleted", LongURL: dest.URL + "/deleted", IsDeleted: true},
+	} {
+		_, err := s.Create(ctx, u)
+		require.NoError(t, err)
+	}
+	_, err = s.CreateWebhook(ctx, storage.Webhook{
+		ID:     "hook",
+		UserID: "user",
+		URL:    hook.URL,
+		Events: storage.WebhookEvents{webhooks.EventLinkBroken},
+	})
+	require.NoError(t, err)
+	dispatcher := webhooks.NewDispatcher(s, safehttp.NewClient(time.Second, true))
+
+	c := NewChecker(s, safehttp.NewClient(time.Second, true))
+	c.HostDelay = time.Millisecond
+	c.Webhooks = dispatcher
+
+	for i := 0; i < storage.BrokenAfterFailures; i++ {
+		require.NoError(t, c.CheckAll(ctx))
+	}
+
+	gone, err := s.GetByID(ctx, "gone")
+	require.NoError(t, err)
+	assert.True(t, gone.Broken())
+	assert.Equal(t, http.StatusNotFound, gone.LinkStatus)
+
+	noHead, err := s.GetByID(ctx, "no-head")
+	require.NoError(t, err)
+	assert.False(t, noHead.Broken())
+	assert.Equal(t, http.StatusOK, noHead.LinkStatus)
+
+	checks, err := s.FindLinkChecks(ctx, "ok2")
+	require.NoError(t, err)
+	assert.Len(t, checks, storage.BrokenAfterFailures)
+
+	mu.Lock()
+	assert.Equal(t, storage.BrokenAfterFailures, requests["HEAD /ok"], "same destination requested once per run")
+	assert.Zero(t, requests["HEAD /deleted"])
+	mu.Unlock()
+
+	require.NoError(t, dispatcher.DeliverDue(ctx))
+	select {
+	case e := <-notifications:
+		assert.Equal(t, webhooks.EventLinkBroken, e.Event)
+		assert.Equal(t, "gone", e.Data.ID)
+		assert.Equal(t, http.StatusNotFound, e.Data.Status)
+	case <-time.After(time.Second):
+		t.Fatal("webhook not notified")
+	}
+	assert.Empty(t, notifications)
+}
@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	tagRe   = regexp.MustCompile(`(?is)<(meta|link)\b([^>]*)>|<title\b[^>]*>(.*?)</title\s*>|</head\s*>`)
+	attrRe  = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	spaceRe = regexp.MustCompile(`\s+`)
+)
+
+const maxTextLength = 300
+
+// parseHTML extracts metadata from the document head, Open Graph values
+// take precedence over the title and description tags.
+func parseHTML(doc []byte, base *url.URL) Metadata {
+	var m Metadata
+	var title, description, ogTitle, ogDescription string
+
+loop:
+	for _, match := range tagRe.FindAllSubmatch(doc, -1) {
+		switch {
+		case match[3] != nil:
+			if title == "" {
+				title = string(match[3])
+			}
+		case match[1] == nil:
+			break loop
+		case strings.EqualFold(string(match[1]), "meta"):
+			attrs := parseAttrs(match[2])
+			content := attrs["content"]
+			switch strings.ToLower(attrs["property"] + attrs["name"]) {
+			case "og:title":
+				ogTitle = content
+			case "og:description":
+				ogDescription = content
+			case "og:image", "og:image:url":
+				if m.Image == "" {
+					m.Image = resolve(base, content)
+				}
+			case "description":
+				description = content
+			}
+		default:
+			attrs := parseAttrs(match[2])
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				if (rel == "icon" || rel == "apple-touch-icon") && m.Favicon == "" {
+					m.Favicon = resolve(base, attrs["href"])
+				}
+			}
+		}
+	}
+
+	m.Title = cleanText(firstNonEmpty(ogTitle, title))
+	m.Description = cleanText(firstNonEmpty(ogDescription, description))
+	if m.Favicon == "" {
+		m.Favicon = resolve(base, "/favicon.ico")
+	}
+
+	return m
+}
+
+func parseAttrs(s []byte) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range attrRe.FindAllStringSubmatch(string(s), -1) {
+		attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2] + match[3] + match[4])
+	}
+
+	return attrs
+}
+
+// resolve makes ref absolute, only http and https links are kept.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	return u.String()
+}
+
+func cleanText(s string) string {
+	s = strings.TrimSpace(spaceRe.ReplaceAllString(html.UnescapeString(s), " "))
+	if r := []rune(s); len(r) > maxTextLength {
+		s = string(r[:maxTextLength])
+	}
+
+	return s
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+
+	return ""
+}
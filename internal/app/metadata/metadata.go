@@ -0,0 +1,82 @@
+// Package metadata fetches titles, descriptions and preview images of link destinations.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/virp/go-shortener/internal/app/safehttp"
+)
+
+var ErrNotHTML = errors.New("destination is not html")
+
+type Metadata struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Favicon     string    `json:"favicon,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// Fetcher downloads destination pages using a client from the safehttp package.
+type Fetcher struct {
+	// MaxBodySize limits how much of a page is read looking for metadata.
+	MaxBodySize int64
+	client      *http.Client
+}
+
+func NewFetcher(client *http.Client, maxBodySize int64) *Fetcher {
+	return &Fetcher{
+		MaxBodySize: maxBodySize,
+		client:      client,
+	}
+}
+
+// Fetch downloads rawURL and extracts its metadata, relative links are
+// resolved against the final URL after redirects.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if err := safehttp.CheckURL(u); err != nil {
+		return Metadata{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Metadata{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "go-shortener-metadata/1.0")
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	if mediaType, _, err := mime.ParseMediaType(res.Header.Get("Content-Type")); err != nil ||
+		(mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return Metadata{}, ErrNotHTML
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, f.MaxBodySize))
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	m := parseHTML(body, res.Request.URL)
+	m.FetchedAt = time.Now()
+
+	return m, nil
+}
@@ -0,0 +1,143 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/safehttp"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<title>
+  Plain &amp; simple
+</title>
+<meta name="description" content="A page description">
+<meta property="og:title" content="Open Graph title">
+<meta property='og:image' content='/images/card.png'>
+<link rel="shortcut icon" href="/static/icon.png">
+</head>
+<body><meta property="og:description" content="ignored in body"></body>
+</html>`
+
+func newServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><head><title>No icon</title></head></html>`))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html><head>" + strings.Repeat(" ", 2048) + "<title>Too far</title></head></html>"))
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	f := NewFetcher(safehttp.NewClient(time.Second, true), 1024)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    Metadata
+		wantErr error
+	}{
+		{
+			name: "open graph tags",
+			path: "/moved",
+			want: Metadata{
+				Title:       "Open Graph title",
+				Description: "A page description",
+				Favicon:     srv.URL + "/static/icon.png",
+				Image:       srv.URL + "/images/card.png",
+			},
+		},
+		{
+			name: "default favicon",
+			path: "/plain",
+			want: Metadata{Title: "No icon", Favicon: srv.URL + "/favicon.ico"},
+		},
+		{
+			name: "size limit",
+			path: "/large",
+			want: Metadata{Favicon: srv.URL + "/favicon.ico"},
+		},
+		{
+			name:    "not html",
+			path:    "/file.pdf",
+			wantErr: ErrNotHTML,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.Fetch(context.Background(), srv.URL+tt.path)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.False(t, got.FetchedAt.IsZero())
+			got.FetchedAt = time.Time{}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFetcher_FetchForbidden(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	f := NewFetcher(safehttp.NewClient(time.Second, false), 1024)
+
+	_, err := f.Fetch(context.Background(), srv.URL+"/page")
+	assert.ErrorIs(t, err, safehttp.ErrForbiddenAddress)
+
+	_, err = f.Fetch(context.Background(), "ftp://example.com/")
+	assert.ErrorIs(t, err, safehttp.ErrUnsupportedURL)
+}
+
+func TestPool(t *testing.T) {
+	srv := newServer()
+	defer srv.Close()
+
+	f := NewFetcher(safehttp.NewClient(time.Second, true), 1024)
+	stored := make(chan Metadata, 1)
+	p := NewPool(f, func(ctx context.Context, id string, m Metadata) error {
+		assert.Equal(t, "1", id)
+		stored <- m
+		return nil
+	}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx, 2)
+
+	require.True(t, p.Enqueue("1", srv.URL+"/page"))
+	select {
+	case m := <-stored:
+		assert.Equal(t, "Open Graph title", m.Title)
+	case <-time.After(time.Second):
+		t.Fatal("metadata not stored")
+	}
+}
@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Store saves fetched metadata of the link with id.
+type Store func(ctx context.Context, id string, m Metadata) error
+
+type job struct {
+	id  string
+	url string
+}
+
+// Pool fetches metadata in the background so link creation never waits for destinations.
+type Pool struct {
+	fetcher *Fetcher
+	store   Store
+	jobs    chan job
+}
+
+func NewPool(fetcher *Fetcher, store Store, queueSize int) *Pool {
+	return &Pool{
+		fetcher: fetcher,
+		store:   store,
+		jobs:    make(chan job, queueSize),
+	}
+}
+
+// Enqueue schedules fetching metadata of url for the link with id,
+// false is returned when the queue is full and the job is dropped.
+func (p *Pool) Enqueue(id, url string) bool {
+	select {
+	case p.jobs <- job{id: id, url: url}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes queued jobs with workers goroutines until ctx is done.
+func (p *Pool) Run(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case j := <-p.jobs:
+					p.process(ctx, j)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) process(ctx context.Context, j job) {
+	m, err := p.fetcher.Fetch(ctx, j.url)
+	if err != nil {
+		log.Printf("fetch metadata of %s: %v", j.id, err)
+		return
+	}
+	if err := p.store(ctx, j.id, m); err != nil {
+		log.Printf("store metadata of %s: %v", j.id, err)
+	}
+}
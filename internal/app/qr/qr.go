@@ -0,0 +1,242 @@
+// Package qr renders QR codes of short links as PNG or SVG images.
+package qr
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+
+	goqr "github.com/skip2/go-qrcode"
+)
+
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid qr format")
+	ErrInvalidLevel  = errors.New("invalid error correction level")
+	ErrInvalidColor  = errors.New("invalid color")
+)
+
+// Options describe how a QR code is rendered, Size is in pixels and Margin in modules.
+type Options struct {
+	Format     string
+	Size       int
+	Level      string
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       256,
+		Level:      "M",
+		Margin:     4,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/png"
+}
+
+func (o Options) key(content string) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%d|%s|%s", content, o.Format, o.Size, o.Level, o.Margin, FormatColor(o.Foreground), FormatColor(o.Background))
+}
+
+// ParseColor parses a color in rrggbb form with an optional leading #.
+func ParseColor(s string) (color.RGBA, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(b) != 3 {
+		return color.RGBA{}, ErrInvalidColor
+	}
+
+	return color.RGBA{R: b[0], G: b[1], B: b[2], A: 0xff}, nil
+}
+
+func FormatColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func recoveryLevel(level string) (goqr.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return goqr.Low, nil
+	case "M":
+		return goqr.Medium, nil
+	case "Q":
+		return goqr.High, nil
+	case "H":
+		return goqr.Highest, nil
+	}
+
+	return 0, ErrInvalidLevel
+}
+
+// ValidLevel reports whether level is one of L, M, Q or H.
+func ValidLevel(level string) bool {
+	_, err := recoveryLevel(level)
+
+	return err == nil
+}
+
+// Render encodes content into an image described by o.
+func Render(content string, o Options) ([]byte, error) {
+	level, err := recoveryLevel(o.Level)
+	if err != nil {
+		return nil, err
+	}
+	code, err := goqr.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	modules := withMargin(code.Bitmap(), o.Margin)
+
+	switch o.Format {
+	case FormatPNG:
+		return renderPNG(modules, o)
+	case FormatSVG:
+		return renderSVG(modules, o), nil
+	}
+
+	return nil, ErrInvalidFormat
+}
+
+func withMargin(bitmap [][]bool, margin int) [][]bool {
+	n := len(bitmap) + 2*margin
+	modules := make([][]bool, n)
+	for y := range modules {
+		modules[y] = make([]bool, n)
+	}
+	for y, row := range bitmap {
+		copy(modules[y+margin][margin:], row)
+	}
+
+	return modules
+}
+
+// renderPNG scales modules by a whole number of pixels and centers them,
+// so the image is never blurry and at least one pixel per module.
+func renderPNG(modules [][]bool, o Options) ([]byte, error) {
+	n := len(modules)
+	size := o.Size
+	if size < n {
+		size = n
+	}
+	scale := size / n
+	offset := (size - n*scale) / 2
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{o.Background, o.Foreground})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func renderSVG(modules [][]bool, o Options) []byte {
+	n := len(modules)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, o.Size, o.Size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, n, n, FormatColor(o.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, FormatColor(o.Foreground))
+	for y, row := range modules {
+		for x := 0; x < n; x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < n && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes()
+}
+
+// Cache keeps recently rendered images, evicting the least recently used ones.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	items   map[string]*list.Element
+	recency *list.List
+}
+
+type cacheItem struct {
+	key  string
+	data []byte
+}
+
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		items:   make(map[string]*list.Element),
+		recency: list.New(),
+	}
+}
+
+// Render returns a cached image or renders and caches a new one.
+func (c *Cache) Render(content string, o Options) ([]byte, error) {
+	key := o.key(content)
+
+	c.mu.Lock()
+	if e, ok := c.items[key]; ok {
+		c.recency.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheItem).data, nil
+	}
+	c.mu.Unlock()
+
+	data, err := Render(content, o)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; !ok && c.size > 0 {
+		c.items[key] = c.recency.PushFront(&cacheItem{key: key, data: data})
+		if c.recency.Len() > c.size {
+			oldest := c.recency.Back()
+			c.recency.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheItem).key)
+		}
+	}
+
+	return data, nil
+}
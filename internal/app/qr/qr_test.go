@@ -0,0 +1,115 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+
+	tests := []struct {
+		name    string
+		opts    func(o *Options)
+		wantErr error
+		check   func(t *testing.T, data []byte)
+	}{
+		{
+			name: "png without margin starts with finder pattern",
+			opts: func(o *Options) {
+				o.Margin = 0
+				o.Foreground = red
+			},
+			check: func(t *testing.T, data []byte) {
+				img, err := png.Decode(bytes.NewReader(data))
+				require.NoError(t, err)
+				assert.Equal(t, 256, img.Bounds().Dx())
+				r, g, b, _ := img.At(12, 12).RGBA()
+				assert.Equal(t, [3]uint32{0xffff, 0, 0}, [3]uint32{r, g, b})
+			},
+		},
+		{
+			name: "png margin is background",
+			check: func(t *testing.T, data []byte) {
+				img, err := png.Decode(bytes.NewReader(data))
+				require.NoError(t, err)
+				r, g, b, _ := img.At(2, 2).RGBA()
+				assert.Equal(t, [3]uint32{0xffff, 0xffff, 0xffff}, [3]uint32{r, g, b})
+			},
+		},
+		{
+			name: "svg",
+			opts: func(o *Options) {
+				o.Format = FormatSVG
+				o.Size = 128
+				o.Background = red
+			},
+			check: func(t *testing.T, data []byte) {
+				svg := string(data)
+				assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="128" height="128"`))
+				assert.Contains(t, svg, `fill="#ff0000"`)
+				assert.Contains(t, svg, `fill="#000000" d="M`)
+			},
+		},
+		{
+			name:    "invalid level",
+			opts:    func(o *Options) { o.Level = "X" },
+			wantErr: ErrInvalidLevel,
+		},
+		{
+			name:    "invalid format",
+			opts:    func(o *Options) { o.Format = "gif" },
+			wantErr: ErrInvalidFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			if tt.opts != nil {
+				tt.opts(&opts)
+			}
+
+			data, err := Render("https://example.com/abc", opts)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, data)
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#1a2B3c")
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, c)
+	assert.Equal(t, "#1a2b3c", FormatColor(c))
+
+	_, err = ParseColor("fff")
+	assert.ErrorIs(t, err, ErrInvalidColor)
+}
+
+func TestCache_Render(t *testing.T) {
+	c := NewCache(1)
+	opts := DefaultOptions()
+
+	first, err := c.Render("https://example.com/1", opts)
+	require.NoError(t, err)
+	again, err := c.Render("https://example.com/1", opts)
+	require.NoError(t, err)
+	assert.Same(t, &first[0], &again[0])
+
+	_, err = c.Render("https://example.com/2", opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.recency.Len())
+	_, ok := c.items[opts.key("https://example.com/1")]
+	assert.False(t, ok)
+}
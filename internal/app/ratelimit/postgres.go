@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// allowQuery takes a token, a bucket is refilled completely by expires_at at the latest.
+const allowQuery = `insert into rate_limits as rl (key, tokens, allowed, updated_at, expires_at)
+values ($1, $2 - 1, true, now(), now() + $4 * interval '1 second')
+on conflict (key) do update set
+    tokens     = least($2, rl.tokens + extract(epoch from now() - rl.updated_at) * $3)
+                 - case when least($2, rl.tokens + extract(epoch from now() - rl.updated_at) * $3) >= 1 then 1 else 0 end,
+    allowed    = least($2, rl.tokens + extract(epoch from now() - rl.updated_at) * $3) >= 1,
+    updated_at = now(),
+    expires_at = now() + $4 * interval '1 second'
+returning tokens, allowed`
+
+// pruneQuery forgets buckets which are full again, a missing bucket is a full one.
+const pruneQuery = `delete from rate_limits where expires_at < now()`
+
+// postgres shares token buckets between service instances using the rate_limits table.
+type postgres struct {
+	db      *sqlx.DB
+	timeout time.Duration
+	calls   int64
+}
+
+func NewPostgresLimiter(db *sqlx.DB, timeout time.Duration) Limiter {
+	return &postgres{
+		db:      db,
+		timeout: timeout,
+	}
+}
+
+func (p *postgres) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var tokens float64
+	var allowed bool
+	err := p.db.QueryRowxContext(ctx, allowQuery, key, limit.Requests, limit.rate(), limit.Per.Seconds()).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, fmt.Errorf("take token: %w", err)
+	}
+
+	if atomic.AddInt64(&p.calls, 1)%pruneEvery == 0 {
+		// The token is taken already, a failed prune is retried by a later call.
+		_, _ = p.db.ExecContext(ctx, pruneQuery)
+	}
+
+	return newResult(limit, tokens, allowed), nil
+}
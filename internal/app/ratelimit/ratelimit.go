@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Per interval with bursts up to Requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("limit %q: expected <requests>/<duration>", s)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid requests count", s)
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid duration", s)
+	}
+
+	return Limit{Requests: requests, Per: per}, nil
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.rate()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(limit.Requests) - tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(math.Max(0, s))) * time.Second
+}
+
+// pruneEvery is the number of Allow calls between prunes of idle buckets.
+const pruneEvery = 1000
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// per is the refill period of the limit the bucket belongs to.
+	per time.Duration
+}
+
+type memory struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	calls   int
+	now     func() time.Time
+}
+
+func NewMemoryLimiter() Limiter {
+	return &memory{
+		buckets: make(map[string]bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%pruneEvery == 0 {
+		m.prune(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(limit.Requests), updatedAt: now}
+	}
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+now.Sub(b.updatedAt).Seconds()*limit.rate())
+	b.updatedAt = now
+	b.per = limit.Per
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	m.buckets[key] = b
+
+	return newResult(limit, b.tokens, allowed), nil
+}
+
+// prune forgets buckets idle long enough to be refilled completely.
+func (m *memory) prune(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.updatedAt) > b.per {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{
+			name:  "disabled",
+			value: "",
+			want:  Limit{},
+		},
+		{
+			name:  "requests per minute",
+			value: "60/1m",
+			want:  Limit{Requests: 60, Per: time.Minute},
+		},
+		{
+			name:    "missing duration",
+			value:   "60",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			value:   "60/minute",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseLimit(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, limit)
+		})
+	}
+}
+
+func TestMemory_Allow(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := &memory{
+		buckets: make(map[string]bucket),
+		now:     func() time.Time { return now },
+	}
+	limit := Limit{Requests: 2, Per: 10 * time.Second}
+
+	res, err := m.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	res, err = m.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, err = m.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 5*time.Second, res.RetryAfter)
+
+	res, err = m.Allow(context.Background(), "other", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(5 * time.Second)
+	res, err = m.Allow(context.Background(), "client", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemory_Prune(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := &memory{
+		buckets: make(map[string]bucket),
+		now:     func() time.Time { return now },
+	}
+	short := Limit{Requests: 1, Per: time.Second}
+	long := Limit{Requests: 1, Per: time.Hour}
+
+	_, err := m.Allow(context.Background(), "password:client", long)
+	require.NoError(t, err)
+	_, err = m.Allow(context.Background(), "redirect:client", short)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	m.prune(now)
+
+	assert.NotContains(t, m.buckets, "redirect:client")
+	res, err := m.Allow(context.Background(), "password:client", long)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+}
@@ -6,37 +6,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strconv"
 	"sync"
+	"time"
 )
 
+const (
+	recordUser              = "user"
+	recordOrg               = "org"
+	recordOrgMember         = "org_member"
+	recordOrgMemberRemove   = "org_member_remove"
+	recordRevision          = "revision"
+	recordURLPurge          = "url_purge"
+	recordUTMTemplate       = "utm_template"
+	recordUTMTemplateDelete = "utm_template_delete"
+	recordClick             = "click"
+	recordLinkCheck         = "link_check"
+	recordWebhook           = "webhook"
+	recordWebhookDelete     = "webhook_delete"
+	recordDelivery          = "delivery"
+	recordDeliveryPurge     = "delivery_purge"
+	recordFolder            = "folder"
+	recordFolderDelete      = "folder_delete"
+	recordTag               = "tag"
+	recordTagDelete         = "tag_delete"
+	recordURLTags           = "url_tags"
+)
+
+// fileRecord wraps every entity except short URLs, which are stored
+// as plain JSON lines for compatibility with existing storage files.
+type fileRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
 type file struct {
-	urls   map[string]ShortURL
-	lastID int
-	mu     *sync.RWMutex
-	f      *os.File
-	w      *bufio.Writer
+	*memory
+	wmu *sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
 }
 
-func NewFileStorage(filename string) (URLStorage, error) {
+func NewFileStorage(filename string) (Storage, error) {
 	f, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0777)
 	if err != nil {
 		return nil, err
 	}
 
-	urls := make(map[string]ShortURL)
-	var lastID int
+	m := newMemory()
 	s := bufio.NewScanner(f)
-	for s.Scan() {
-		var url ShortURL
-		err := json.Unmarshal(s.Bytes(), &url)
-		if err != nil {
-			return nil, err
-		}
-		urls[url.ID] = url
-
-		if id, err := strconv.Atoi(url.ID); err == nil && id > lastID {
-			lastID = id
+	for line := 1; s.Scan(); line++ {
+		if err := restoreRecord(m, s.Bytes()); err != nil {
+			return nil, fmt.Errorf("restore %s line %d: %w", filename, line, err)
 		}
 	}
 	if err = s.Err(); err != nil {
@@ -46,86 +66,372 @@ func NewFileStorage(filename string) (URLStorage, error) {
 	w := bufio.NewWriter(f)
 
 	return &file{
-		urls:   urls,
-		lastID: lastID,
-		mu:     new(sync.RWMutex),
+		memory: m,
+		wmu:    new(sync.Mutex),
 		f:      f,
 		w:      w,
 	}, nil
 }
 
-func (s *file) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.lastID = s.lastID + 1
-	if url.ID == "" {
-		url.ID = strconv.Itoa(s.lastID)
+func restoreRecord(m *memory, line []byte) error {
+	var rec fileRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return err
 	}
 
-	if u, ok := s.urls[url.ID]; ok {
-		return u, ErrAlreadyExist
+	switch rec.Type {
+	case "":
+		var url ShortURL
+		if err := json.Unmarshal(line, &url); err != nil {
+			return err
+		}
+		m.putURL(url)
+	case recordUser:
+		var user User
+		if err := json.Unmarshal(rec.Data, &user); err != nil {
+			return err
+		}
+		m.putUser(user)
+	case recordOrg:
+		var org Org
+		if err := json.Unmarshal(rec.Data, &org); err != nil {
+			return err
+		}
+		m.putOrg(org)
+	case recordOrgMember:
+		var member OrgMember
+		if err := json.Unmarshal(rec.Data, &member); err != nil {
+			return err
+		}
+		m.putOrgMember(member)
+	case recordOrgMemberRemove:
+		var member OrgMember
+		if err := json.Unmarshal(rec.Data, &member); err != nil {
+			return err
+		}
+		if err := m.RemoveOrgMember(context.Background(), member.OrgID, member.UserID); err != nil {
+			return err
+		}
+	case recordRevision:
+		var rev Revision
+		if err := json.Unmarshal(rec.Data, &rev); err != nil {
+			return err
+		}
+		m.putRevision(rev)
+	case recordURLPurge:
+		var id string
+		if err := json.Unmarshal(rec.Data, &id); err != nil {
+			return err
+		}
+		m.removeURL(id)
+	case recordUTMTemplate:
+		var tmpl UTMTemplate
+		if err := json.Unmarshal(rec.Data, &tmpl); err != nil {
+			return err
+		}
+		if err := m.SaveUTMTemplate(context.Background(), tmpl); err != nil {
+			return err
+		}
+	case recordUTMTemplateDelete:
+		var tmpl UTMTemplate
+		if err := json.Unmarshal(rec.Data, &tmpl); err != nil {
+			return err
+		}
+		if err := m.DeleteUTMTemplate(context.Background(), tmpl.UserID, tmpl.Name); err != nil {
+			return err
+		}
+	case recordClick:
+		var click Click
+		if err := json.Unmarshal(rec.Data, &click); err != nil {
+			return err
+		}
+		if err := m.RecordClick(context.Background(), click); err != nil {
+			return err
+		}
+	case recordLinkCheck:
+		var check LinkCheck
+		if err := json.Unmarshal(rec.Data, &check); err != nil {
+			return err
+		}
+		if _, err := m.RecordLinkCheck(context.Background(), check); err != nil {
+			return err
+		}
+	case recordWebhook:
+		var hook Webhook
+		if err := json.Unmarshal(rec.Data, &hook); err != nil {
+			return err
+		}
+		if _, err := m.CreateWebhook(context.Background(), hook); err != nil {
+			return err
+		}
+	case recordWebhookDelete:
+		var hook Webhook
+		if err := json.Unmarshal(rec.Data, &hook); err != nil {
+			return err
+		}
+		if err := m.DeleteWebhook(context.Background(), hook.UserID, hook.ID); err != nil {
+			return err
+		}
+	case recordDelivery:
+		var d Delivery
+		if err := json.Unmarshal(rec.Data, &d); err != nil {
+			return err
+		}
+		if err := m.SaveDelivery(context.Background(), d); err != nil {
+			return err
+		}
+	case recordDeliveryPurge:
+		var id string
+		if err := json.Unmarshal(rec.Data, &id); err != nil {
+			return err
+		}
+		m.removeDelivery(id)
+	case recordFolder:
+		var folder Folder
+		if err := json.Unmarshal(rec.Data, &folder); err != nil {
+			return err
+		}
+		m.putFolder(folder)
+	case recordFolderDelete:
+		var folder Folder
+		if err := json.Unmarshal(rec.Data, &folder); err != nil {
+			return err
+		}
+		if _, err := m.deleteFolder(folder.UserID, folder.ID); err != nil {
+			return err
+		}
+	case recordTag:
+		var tag Tag
+		if err := json.Unmarshal(rec.Data, &tag); err != nil {
+			return err
+		}
+		m.putTag(tag)
+	case recordTagDelete:
+		var tag Tag
+		if err := json.Unmarshal(rec.Data, &tag); err != nil {
+			return err
+		}
+		if err := m.DeleteTag(context.Background(), tag.UserID, tag.ID); err != nil {
+			return err
+		}
+	case recordURLTags:
+		var change urlTagsRecord
+		if err := json.Unmarshal(rec.Data, &change); err != nil {
+			return err
+		}
+		if err := m.TagURLs(context.Background(), change.IDs, change.Add, change.Remove); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown record type %q", rec.Type)
 	}
 
-	s.urls[url.ID] = url
+	return nil
+}
 
-	data, err := json.Marshal(url)
+func (s *file) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.Create(ctx, url)
 	if err != nil {
-		return ShortURL{}, err
-	}
-	if _, err := s.w.Write(data); err != nil {
-		return ShortURL{}, err
-	}
-	if err = s.w.WriteByte('\n'); err != nil {
-		return ShortURL{}, err
+		return url, err
 	}
-	if err = s.w.Flush(); err != nil {
+
+	if err := s.write(url); err != nil {
 		return ShortURL{}, err
 	}
 
 	return url, nil
 }
 
-func (s *file) GetByID(ctx context.Context, id string) (ShortURL, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *file) CreateBatch(ctx context.Context, urls []ShortURL, quota Quota) ([]ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
 
-	url, ok := s.urls[id]
-	if !ok {
-		return ShortURL{}, ErrNotFound
+	createdUrls, err := s.memory.CreateBatch(ctx, urls, quota)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range createdUrls {
+		if !url.Inserted {
+			continue
+		}
+		if err := s.write(url); err != nil {
+			return nil, err
+		}
 	}
 
-	return url, nil
+	return createdUrls, nil
 }
 
-func (s *file) FindByUserID(ctx context.Context, userID string) []ShortURL {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *file) DeleteBatch(ctx context.Context, userID string, ids []string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	for _, url := range s.memory.deleteBatch(userID, ids) {
+		if err := s.write(url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	var urls []ShortURL
+func (s *file) RestoreBatch(ctx context.Context, userID string, ids []string, deletedAfter time.Time) ([]ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
 
-	for _, url := range s.urls {
-		if url.UserID == userID {
-			urls = append(urls, url)
+	restored, err := s.memory.RestoreBatch(ctx, userID, ids, deletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range restored {
+		if err := s.write(url); err != nil {
+			return nil, err
 		}
 	}
 
-	return urls
+	return restored, nil
 }
 
-func (s *file) CreateBatch(ctx context.Context, urls []ShortURL) ([]ShortURL, error) {
-	createdUrls := make([]ShortURL, 0, len(urls))
-	for _, u := range urls {
-		cu, err := s.Create(ctx, u)
-		if err != nil {
-			return nil, fmt.Errorf("create url: %w", err)
+func (s *file) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	purged := s.memory.purgeDeleted(deletedBefore)
+	for _, id := range purged {
+		if err := s.writeRecord(recordURLPurge, id); err != nil {
+			return 0, err
 		}
-		createdUrls = append(createdUrls, cu)
 	}
 
-	return createdUrls, nil
+	return len(purged), nil
 }
 
-func (s *file) DeleteBatch(ctx context.Context, userID string, ids []string) error {
+func (s *file) CreateUser(ctx context.Context, user User) (User, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	user, err := s.memory.CreateUser(ctx, user)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := s.writeRecord(recordUser, user); err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *file) MergeUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	// Records are ordered so replaying them repeats the merge: links are re-tagged
+	// before duplicate tags are deleted, moved templates are saved under the new owner.
+	m := s.memory.mergeUser(fromUserID, toUserID)
+	for _, url := range m.urls {
+		if err := s.write(url); err != nil {
+			return err
+		}
+	}
+	for _, folder := range m.folders {
+		if err := s.writeRecord(recordFolder, folder); err != nil {
+			return err
+		}
+	}
+	for _, folder := range m.deletedFolders {
+		if err := s.writeRecord(recordFolderDelete, folder); err != nil {
+			return err
+		}
+	}
+	for _, tag := range m.tags {
+		if err := s.writeRecord(recordTag, tag); err != nil {
+			return err
+		}
+	}
+	for _, change := range m.retagged {
+		if err := s.writeRecord(recordURLTags, change); err != nil {
+			return err
+		}
+	}
+	for _, tag := range m.deletedTags {
+		if err := s.writeRecord(recordTagDelete, tag); err != nil {
+			return err
+		}
+	}
+	for _, tmpl := range m.deletedTemplates {
+		if err := s.writeRecord(recordUTMTemplateDelete, tmpl); err != nil {
+			return err
+		}
+	}
+	for _, tmpl := range m.templates {
+		if err := s.writeRecord(recordUTMTemplate, tmpl); err != nil {
+			return err
+		}
+	}
+	for _, hook := range m.webhooks {
+		if err := s.writeRecord(recordWebhook, hook); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+func (s *file) SetPublicStats(ctx context.Context, id string, public bool) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.SetPublicStats(ctx, id, public)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if err := s.write(url); err != nil {
+		return ShortURL{}, err
+	}
+
+	return url, nil
+}
+
+func (s *file) SetMetadata(ctx context.Context, id string, metadata LinkMetadata) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.SetMetadata(ctx, id, metadata)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if err := s.write(url); err != nil {
+		return ShortURL{}, err
+	}
+
+	return url, nil
+}
+
+func (s *file) writeRecord(recordType string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.write(fileRecord{Type: recordType, Data: data})
+}
+
+// write appends v as a JSON line, later lines override earlier ones on restore.
+func (s *file) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	if err = s.w.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	return s.w.Flush()
+}
@@ -0,0 +1,29 @@
+package storage
+
+import "context"
+
+func (s *file) ConsumeClick(ctx context.Context, id string) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.ConsumeClick(ctx, id)
+	if err != nil || url.MaxClicks == 0 {
+		return url, err
+	}
+	if err := s.write(url); err != nil {
+		return ShortURL{}, err
+	}
+
+	return url, nil
+}
+
+func (s *file) RecordClick(ctx context.Context, click Click) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.RecordClick(ctx, click); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordClick, click)
+}
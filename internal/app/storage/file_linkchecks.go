@@ -0,0 +1,18 @@
+package storage
+
+import "context"
+
+func (s *file) RecordLinkCheck(ctx context.Context, check LinkCheck) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.RecordLinkCheck(ctx, check)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if err := s.writeRecord(recordLinkCheck, check); err != nil {
+		return ShortURL{}, err
+	}
+
+	return url, nil
+}
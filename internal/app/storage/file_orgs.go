@@ -0,0 +1,44 @@
+package storage
+
+import "context"
+
+func (s *file) CreateOrg(ctx context.Context, org Org, ownerID string) (Org, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	org, err := s.memory.CreateOrg(ctx, org, ownerID)
+	if err != nil {
+		return Org{}, err
+	}
+	if err := s.writeRecord(recordOrg, org); err != nil {
+		return Org{}, err
+	}
+	owner := OrgMember{OrgID: org.ID, UserID: ownerID, Role: RoleOwner}
+	if err := s.writeRecord(recordOrgMember, owner); err != nil {
+		return Org{}, err
+	}
+
+	return org, nil
+}
+
+func (s *file) SetOrgMember(ctx context.Context, member OrgMember) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.SetOrgMember(ctx, member); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordOrgMember, member)
+}
+
+func (s *file) RemoveOrgMember(ctx context.Context, orgID, userID string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.RemoveOrgMember(ctx, orgID, userID); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordOrgMemberRemove, OrgMember{OrgID: orgID, UserID: userID})
+}
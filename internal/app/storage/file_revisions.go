@@ -0,0 +1,21 @@
+package storage
+
+import "context"
+
+func (s *file) UpdateTarget(ctx context.Context, url ShortURL, editorID string) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	updated, rev, err := s.memory.updateTarget(url, editorID)
+	if err != nil {
+		return updated, err
+	}
+	if err := s.writeRecord(recordRevision, rev); err != nil {
+		return ShortURL{}, err
+	}
+	if err := s.write(updated); err != nil {
+		return ShortURL{}, err
+	}
+
+	return updated, nil
+}
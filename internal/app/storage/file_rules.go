@@ -0,0 +1,33 @@
+package storage
+
+import "context"
+
+func (s *file) SetRules(ctx context.Context, id string, rules RedirectRules) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.SetRules(ctx, id, rules)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if err := s.write(url); err != nil {
+		return ShortURL{}, err
+	}
+
+	return url, nil
+}
+
+func (s *file) SetVariants(ctx context.Context, id string, variants Variants) (ShortURL, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	url, err := s.memory.SetVariants(ctx, id, variants)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if err := s.write(url); err != nil {
+		return ShortURL{}, err
+	}
+
+	return url, nil
+}
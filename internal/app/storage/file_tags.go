@@ -0,0 +1,126 @@
+package storage
+
+import "context"
+
+// urlTagsRecord is a bulk tagging change of links.
+type urlTagsRecord struct {
+	IDs    []string `json:"ids"`
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+func (s *file) CreateFolder(ctx context.Context, folder Folder) (Folder, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	folder, err := s.memory.CreateFolder(ctx, folder)
+	if err != nil {
+		return Folder{}, err
+	}
+	if err := s.writeRecord(recordFolder, folder); err != nil {
+		return Folder{}, err
+	}
+
+	return folder, nil
+}
+
+func (s *file) RenameFolder(ctx context.Context, userID, id, name string) (Folder, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	folder, err := s.memory.RenameFolder(ctx, userID, id, name)
+	if err != nil {
+		return Folder{}, err
+	}
+	if err := s.writeRecord(recordFolder, folder); err != nil {
+		return Folder{}, err
+	}
+
+	return folder, nil
+}
+
+func (s *file) DeleteFolder(ctx context.Context, userID, id string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	unfiled, err := s.memory.deleteFolder(userID, id)
+	if err != nil {
+		return err
+	}
+	for _, url := range unfiled {
+		if err := s.write(url); err != nil {
+			return err
+		}
+	}
+
+	return s.writeRecord(recordFolderDelete, Folder{ID: id, UserID: userID})
+}
+
+func (s *file) MoveURLs(ctx context.Context, ids []string, folderID string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	moved, err := s.memory.moveURLs(ids, folderID)
+	if err != nil {
+		return err
+	}
+	for _, url := range moved {
+		if err := s.write(url); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *file) CreateTag(ctx context.Context, tag Tag) (Tag, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	tag, err := s.memory.CreateTag(ctx, tag)
+	if err != nil {
+		return Tag{}, err
+	}
+	if err := s.writeRecord(recordTag, tag); err != nil {
+		return Tag{}, err
+	}
+
+	return tag, nil
+}
+
+func (s *file) RenameTag(ctx context.Context, userID, id, name string) (Tag, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	tag, err := s.memory.RenameTag(ctx, userID, id, name)
+	if err != nil {
+		return Tag{}, err
+	}
+	if err := s.writeRecord(recordTag, tag); err != nil {
+		return Tag{}, err
+	}
+
+	return tag, nil
+}
+
+func (s *file) DeleteTag(ctx context.Context, userID, id string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.DeleteTag(ctx, userID, id); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordTagDelete, Tag{ID: id, UserID: userID})
+}
+
+func (s *file) TagURLs(ctx context.Context, ids, add, remove []string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.TagURLs(ctx, ids, add, remove); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordURLTags, urlTagsRecord{IDs: ids, Add: add, Remove: remove})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -76,3 +77,305 @@ func getTmpFilename() (string, error) {
 func removeTmpFile(filename string) error {
 	return os.Remove(filename)
 }
+
+func TestFile_CorruptLog(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	log := `{"id":"1","url":"https://example.com/"}
+{"type":"tag_delete","data":{"ID":"missing","UserID":"user"}}
+`
+	require.NoError(t, os.WriteFile(filename, []byte(log), 0644))
+
+	_, err = NewFileStorage(filename)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestFile_MergeUserURLs(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = s.CreateUser(ctx, User{ID: "account", Login: "alice", PasswordHash: "hash"})
+	require.NoError(t, err)
+	accountTag, err := s.CreateTag(ctx, Tag{ID: "account-promo", UserID: "account", Name: "promo"})
+	require.NoError(t, err)
+	_, err = s.CreateTag(ctx, Tag{ID: "anonymous-promo", UserID: "anonymous", Name: "promo"})
+	require.NoError(t, err)
+	_, err = s.CreateTag(ctx, Tag{ID: "anonymous-spring", UserID: "anonymous", Name: "spring"})
+	require.NoError(t, err)
+	require.NoError(t, s.SaveUTMTemplate(ctx, UTMTemplate{UserID: "anonymous", Name: "newsletter", Source: "mail"}))
+	_, err = s.CreateWebhook(ctx, Webhook{ID: "hook", UserID: "anonymous", URL: "https://crm.example.org/hook", Secret: "secret"})
+	require.NoError(t, err)
+	url, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/long", UserID: "anonymous", UTMTemplate: "newsletter"})
+	require.NoError(t, err)
+	require.NoError(t, s.TagURLs(ctx, []string{url.ID}, []string{"anonymous-promo", "anonymous-spring"}, nil))
+	err = s.MergeUserURLs(ctx, "anonymous", "account")
+	require.NoError(t, err)
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	user, err := s.GetUserByLogin(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "account", user.ID)
+	assert.Len(t, s.FindByUserID(ctx, "account"), 1)
+	assert.Empty(t, s.FindByUserID(ctx, "anonymous"))
+
+	tags, err := s.FindURLTags(ctx, "account", []string{url.ID})
+	require.NoError(t, err)
+	require.Len(t, tags[url.ID], 2)
+	assert.Equal(t, accountTag.ID, tags[url.ID][0].ID)
+	assert.Equal(t, "spring", tags[url.ID][1].Name)
+	userTags, err := s.FindUserTags(ctx, "anonymous")
+	require.NoError(t, err)
+	assert.Empty(t, userTags)
+
+	tmpl, err := s.GetUTMTemplate(ctx, "account", "newsletter")
+	require.NoError(t, err)
+	assert.Equal(t, "mail", tmpl.Source)
+	assert.Empty(t, s.FindUTMTemplates(ctx, "anonymous"))
+	hook, err := s.GetWebhook(ctx, "hook")
+	require.NoError(t, err)
+	assert.Equal(t, "account", hook.UserID)
+}
+
+func TestFile_UpdateTarget(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	url, err := s.Create(context.Background(), ShortURL{LongURL: "https://example.com/typo"})
+	require.NoError(t, err)
+	url.LongURL = "https://example.com/fixed"
+	_, err = s.UpdateTarget(context.Background(), url, "editor")
+	require.NoError(t, err)
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	url, err = s.GetByID(context.Background(), url.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/fixed", url.LongURL)
+
+	revisions := s.FindRevisions(context.Background(), url.ID)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "https://example.com/typo", revisions[0].LongURL)
+	assert.Equal(t, "editor", revisions[0].EditorID)
+}
+
+func TestFile_PurgeDeleted(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	url, err := s.Create(context.Background(), ShortURL{LongURL: "https://example.com/long", UserID: "user"})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteBatch(context.Background(), "user", []string{url.ID}))
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	deleted, err := s.GetByID(context.Background(), url.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted)
+	assert.NotNil(t, deleted.DeletedAt)
+
+	purged, err := s.PurgeDeleted(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	_, err = s.GetByID(context.Background(), url.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFile_ConsumeClick(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	url, err := s.Create(context.Background(), ShortURL{LongURL: "https://example.com/once", MaxClicks: 2, ClicksLeft: 2})
+	require.NoError(t, err)
+	url, err = s.ConsumeClick(context.Background(), url.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, url.ClicksLeft)
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	_, err = s.ConsumeClick(context.Background(), url.ID)
+	require.NoError(t, err)
+	_, err = s.ConsumeClick(context.Background(), url.ID)
+	assert.ErrorIs(t, err, ErrExhausted)
+}
+
+func TestFile_RecordLinkCheck(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	url, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/gone"})
+	require.NoError(t, err)
+	checkedAt := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < BrokenAfterFailures; i++ {
+		url, err = s.RecordLinkCheck(ctx, LinkCheck{URLID: url.ID, Status: 404, CheckedAt: checkedAt.Add(time.Duration(i) * time.Hour)})
+		require.NoError(t, err)
+	}
+	assert.True(t, url.Broken())
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	url, err = s.GetByID(ctx, url.ID)
+	require.NoError(t, err)
+	assert.True(t, url.Broken())
+	assert.Equal(t, 404, url.LinkStatus)
+
+	url, err = s.RecordLinkCheck(ctx, LinkCheck{URLID: url.ID, Status: 200, CheckedAt: checkedAt.Add(5 * time.Hour)})
+	require.NoError(t, err)
+	assert.False(t, url.Broken())
+
+	checks, err := s.FindLinkChecks(ctx, url.ID)
+	require.NoError(t, err)
+	require.Len(t, checks, 3)
+	assert.Equal(t, 200, checks[0].Status)
+}
+
+func TestFile_Webhooks(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, id := range []string{"kept", "removed"} {
+		_, err := s.CreateWebhook(ctx, Webhook{ID: id, UserID: "user", URL: "https://example.com/" + id, Events: WebhookEvents{"link.created"}})
+		require.NoError(t, err)
+		require.NoError(t, s.SaveDelivery(ctx, Delivery{ID: id + "-1", WebhookID: id, Status: DeliveryPending}))
+	}
+	require.NoError(t, s.SaveDelivery(ctx, Delivery{ID: "kept-1", WebhookID: "kept", Status: DeliveryDead, Attempts: 3, CreatedAt: time.Now()}))
+	require.NoError(t, s.DeleteWebhook(ctx, "user", "removed"))
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, s.SaveDelivery(ctx, Delivery{ID: "kept-0", WebhookID: "kept", Status: DeliveryDelivered, CreatedAt: old, DeliveredAt: &old}))
+	purged, err := s.PurgeDeliveries(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+	hooks, err := s.FindUserWebhooks(ctx, "user")
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	assert.Equal(t, WebhookEvents{"link.created"}, hooks[0].Events)
+
+	deliveries, err := s.FindDeliveries(ctx, "kept", "")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryDead, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+
+	due, err := s.ClaimDueDeliveries(ctx, time.Now(), time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestFile_FoldersAndTags(t *testing.T) {
+	filename, err := getTmpFilename()
+	require.NoError(t, err)
+	defer func() {
+		err := removeTmpFile(filename)
+		require.NoError(t, err)
+	}()
+
+	s, err := NewFileStorage(filename)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		_, err := s.Create(ctx, ShortURL{ID: id, LongURL: "https://example.com/" + id, UserID: "user"})
+		require.NoError(t, err)
+	}
+	for _, id := range []string{"work", "old"} {
+		_, err := s.CreateFolder(ctx, Folder{ID: id, UserID: "user", Name: id})
+		require.NoError(t, err)
+		_, err = s.CreateTag(ctx, Tag{ID: id, UserID: "user", Name: id})
+		require.NoError(t, err)
+	}
+	_, err = s.CreateTag(ctx, Tag{ID: "dup", UserID: "user", Name: "work"})
+	assert.ErrorIs(t, err, ErrNameTaken)
+	_, err = s.RenameTag(ctx, "user", "work", "old")
+	assert.ErrorIs(t, err, ErrNameTaken)
+
+	require.NoError(t, s.MoveURLs(ctx, []string{"a"}, "work"))
+	require.NoError(t, s.MoveURLs(ctx, []string{"b"}, "old"))
+	assert.ErrorIs(t, s.MoveURLs(ctx, []string{"b"}, "missing"), ErrNotFound)
+	require.NoError(t, s.DeleteFolder(ctx, "user", "old"))
+	_, err = s.RenameFolder(ctx, "user", "work", "projects")
+	require.NoError(t, err)
+
+	require.NoError(t, s.TagURLs(ctx, []string{"a", "b"}, []string{"work", "old"}, nil))
+	require.NoError(t, s.TagURLs(ctx, []string{"b"}, nil, []string{"work"}))
+	require.NoError(t, s.DeleteTag(ctx, "user", "old"))
+
+	s, err = NewFileStorage(filename)
+	require.NoError(t, err)
+
+	folders, err := s.FindUserFolders(ctx, "user")
+	require.NoError(t, err)
+	require.Len(t, folders, 1)
+	assert.Equal(t, "projects", folders[0].Name)
+
+	a, err := s.GetByID(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "work", a.FolderID)
+	b, err := s.GetByID(ctx, "b")
+	require.NoError(t, err)
+	assert.Empty(t, b.FolderID)
+
+	tags, err := s.FindURLTags(ctx, "user", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Tag{"a": {{ID: "work", UserID: "user", Name: "work", CreatedAt: tags["a"][0].CreatedAt}}}, tags)
+
+	tags, err = s.FindURLTags(ctx, "other", []string{"a"})
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "context"
+
+func (s *file) SaveUTMTemplate(ctx context.Context, tmpl UTMTemplate) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.SaveUTMTemplate(ctx, tmpl); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordUTMTemplate, tmpl)
+}
+
+func (s *file) DeleteUTMTemplate(ctx context.Context, userID, name string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.DeleteUTMTemplate(ctx, userID, name); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordUTMTemplateDelete, UTMTemplate{UserID: userID, Name: name})
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+func (s *file) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	hook, err := s.memory.CreateWebhook(ctx, hook)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if err := s.writeRecord(recordWebhook, hook); err != nil {
+		return Webhook{}, err
+	}
+
+	return hook, nil
+}
+
+func (s *file) DeleteWebhook(ctx context.Context, userID, id string) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.DeleteWebhook(ctx, userID, id); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordWebhookDelete, Webhook{ID: id, UserID: userID})
+}
+
+func (s *file) SaveDelivery(ctx context.Context, d Delivery) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.memory.SaveDelivery(ctx, d); err != nil {
+		return err
+	}
+
+	return s.writeRecord(recordDelivery, d)
+}
+
+func (s *file) PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	purged := s.memory.purgeDeliveries(finishedBefore)
+	for _, id := range purged {
+		if err := s.writeRecord(recordDeliveryPurge, id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(purged), nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import "time"
+
+// BrokenAfterFailures is the number of consecutive failed checks marking a link broken,
+// so a single network hiccup does not flag it.
+const BrokenAfterFailures = 2
+
+// LinkCheck is a result of requesting a link destination, Status is zero
+// when no response was received and Error tells why.
+type LinkCheck struct {
+	URLID     string    `db:"url_id"`
+	Status    int       `db:"status"`
+	Error     string    `db:"error"`
+	CheckedAt time.Time `db:"checked_at"`
+}
+
+func (c LinkCheck) OK() bool {
+	return c.Error == "" && c.Status > 0 && c.Status < 400
+}
+
+func (u ShortURL) Broken() bool {
+	return u.LinkFailures >= BrokenAfterFailures
+}
+
+// checkable reports whether url is served at now, the same way redirects decide,
+// and its destination may be requested outside of redirects. Destinations of
+// click limited and password protected links are one-time or private ones.
+func (u ShortURL) checkable(now time.Time) bool {
+	switch {
+	case u.IsDeleted, u.MaxClicks > 0, u.PasswordHash != "":
+		return false
+	case u.NotBefore != nil && now.Before(*u.NotBefore):
+		return false
+	case u.NotAfter != nil && !now.Before(*u.NotAfter):
+		return false
+	}
+
+	return true
+}
+
+// applyLinkCheck updates the last check state of url.
+func applyLinkCheck(url *ShortURL, check LinkCheck) {
+	url.LinkStatus = check.Status
+	url.LinkCheckedAt = &check.CheckedAt
+	if check.OK() {
+		url.LinkFailures = 0
+	} else {
+		url.LinkFailures++
+	}
+}
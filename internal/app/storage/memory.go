@@ -2,43 +2,127 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type memory struct {
-	urls   map[string]ShortURL
-	lastID int
-	mu     *sync.RWMutex
+	urls           map[string]ShortURL
+	userURLs       map[string]map[string]struct{}
+	canonicalURLs  map[string]string
+	users          map[string]User
+	orgs           map[string]Org
+	members        map[string]map[string]OrgMember
+	revisions      map[string][]Revision
+	utmTemplates   map[utmTemplateKey]UTMTemplate
+	clicks         map[string][]Click
+	unrolledClicks []Click
+	rollups        map[rollupKey]Rollup
+	linkChecks     map[string][]LinkCheck
+	webhooks       map[string]Webhook
+	deliveries     map[string]Delivery
+	folders        map[string]Folder
+	tags           map[string]Tag
+	urlTags        map[string]map[string]struct{}
+	lastID         int
+	lastRevisionID int
+	mu             *sync.RWMutex
 }
 
-func NewMemoryStorage() (URLStorage, error) {
+func NewMemoryStorage() (Storage, error) {
+	return newMemory(), nil
+}
+
+func newMemory() *memory {
 	return &memory{
-		urls:   make(map[string]ShortURL),
-		lastID: 0,
-		mu:     new(sync.RWMutex),
-	}, nil
+		urls:          make(map[string]ShortURL),
+		userURLs:      make(map[string]map[string]struct{}),
+		canonicalURLs: make(map[string]string),
+		users:         make(map[string]User),
+		orgs:          make(map[string]Org),
+		members:       make(map[string]map[string]OrgMember),
+		revisions:     make(map[string][]Revision),
+		utmTemplates:  make(map[utmTemplateKey]UTMTemplate),
+		clicks:        make(map[string][]Click),
+		rollups:       make(map[rollupKey]Rollup),
+		linkChecks:    make(map[string][]LinkCheck),
+		webhooks:      make(map[string]Webhook),
+		deliveries:    make(map[string]Delivery),
+		folders:       make(map[string]Folder),
+		tags:          make(map[string]Tag),
+		urlTags:       make(map[string]map[string]struct{}),
+		lastID:        0,
+		mu:            new(sync.RWMutex),
+	}
 }
 
 func (s *memory) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.createLocked(url)
+}
+
+func (s *memory) createLocked(url ShortURL) (ShortURL, error) {
+	if u, ok := s.findDuplicateLocked(url); ok {
+		return u, ErrAlreadyExist
+	}
+
 	s.lastID = s.lastID + 1
 	if url.ID == "" {
 		url.ID = strconv.Itoa(s.lastID)
 	}
-
 	if u, ok := s.urls[url.ID]; ok {
 		return u, ErrAlreadyExist
 	}
 
-	s.urls[url.ID] = url
+	if url.CreatedAt.IsZero() {
+		url.CreatedAt = time.Now()
+	}
+	s.setURLLocked(url)
 
 	return url, nil
 }
 
+// findDuplicateLocked returns another plain link with the canonical URL of plain url.
+func (s *memory) findDuplicateLocked(url ShortURL) (ShortURL, bool) {
+	if url.CanonicalURL == "" || url.HasOptions() {
+		return ShortURL{}, false
+	}
+	id, ok := s.canonicalURLs[url.CanonicalURL]
+	if !ok || id == url.ID {
+		return ShortURL{}, false
+	}
+
+	return s.urls[id], true
+}
+
+// setURLLocked stores url keeping indexes of links by user and of plain links
+// by canonical URL up to date.
+func (s *memory) setURLLocked(url ShortURL) {
+	if old, ok := s.urls[url.ID]; ok {
+		if old.UserID != url.UserID {
+			delete(s.userURLs[old.UserID], url.ID)
+		}
+		if s.canonicalURLs[old.CanonicalURL] == url.ID {
+			delete(s.canonicalURLs, old.CanonicalURL)
+		}
+	}
+	if _, taken := s.canonicalURLs[url.CanonicalURL]; url.CanonicalURL != "" && !url.HasOptions() && !taken {
+		s.canonicalURLs[url.CanonicalURL] = url.ID
+	}
+	ids, ok := s.userURLs[url.UserID]
+	if !ok {
+		ids = make(map[string]struct{})
+		s.userURLs[url.UserID] = ids
+	}
+	ids[url.ID] = struct{}{}
+	s.urls[url.ID] = url
+}
+
 func (s *memory) GetByID(ctx context.Context, id string) (ShortURL, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -57,22 +141,62 @@ func (s *memory) FindByUserID(ctx context.Context, userID string) []ShortURL {
 
 	var urls []ShortURL
 
-	for _, url := range s.urls {
-		if url.UserID == userID {
-			urls = append(urls, url)
-		}
+	for id := range s.userURLs[userID] {
+		urls = append(urls, s.urls[id])
 	}
 
 	return urls
 }
 
-func (s *memory) CreateBatch(ctx context.Context, urls []ShortURL) ([]ShortURL, error) {
+func (s *memory) CountUserURLs(ctx context.Context, userID string, since time.Time) (URLCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countUserURLsLocked(userID, since), nil
+}
+
+func (s *memory) countUserURLsLocked(userID string, since time.Time) URLCounts {
+	var counts URLCounts
+	for id := range s.userURLs[userID] {
+		url := s.urls[id]
+		if !url.IsDeleted {
+			counts.Active++
+		}
+		if !url.CreatedAt.Before(since) {
+			counts.CreatedSince++
+		}
+	}
+
+	return counts
+}
+
+func (s *memory) CreateBatch(ctx context.Context, urls []ShortURL, quota Quota) ([]ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if quota.Enabled() && len(urls) > 0 {
+		n := 0
+		for _, u := range urls {
+			if _, ok := s.urls[u.ID]; u.ID != "" && ok {
+				continue
+			}
+			if _, ok := s.findDuplicateLocked(u); !ok {
+				n++
+			}
+		}
+		if err := quota.check(s.countUserURLsLocked(urls[0].UserID, quota.DayStart), n); err != nil {
+			return nil, err
+		}
+	}
+
 	createdUrls := make([]ShortURL, 0, len(urls))
 	for _, u := range urls {
-		cu, err := s.Create(ctx, u)
-		if err != nil {
+		cu, err := s.createLocked(u)
+		if err != nil && !errors.Is(err, ErrAlreadyExist) {
 			return nil, fmt.Errorf("create url: %w", err)
 		}
+		cu.CorrelationID = u.CorrelationID
+		cu.Inserted = err == nil
 		createdUrls = append(createdUrls, cu)
 	}
 
@@ -80,5 +204,309 @@ func (s *memory) CreateBatch(ctx context.Context, urls []ShortURL) ([]ShortURL,
 }
 
 func (s *memory) DeleteBatch(ctx context.Context, userID string, ids []string) error {
+	s.deleteBatch(userID, ids)
+
+	return nil
+}
+
+func (s *memory) deleteBatch(userID string, ids []string) []ShortURL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []ShortURL
+	for _, id := range ids {
+		url, ok := s.urls[id]
+		if !ok || url.IsDeleted || !s.canEditLocked(url, userID) {
+			continue
+		}
+		now := time.Now()
+		url.IsDeleted = true
+		url.DeletedAt = &now
+		s.urls[id] = url
+		deleted = append(deleted, url)
+	}
+
+	return deleted
+}
+
+func (s *memory) RestoreBatch(ctx context.Context, userID string, ids []string, deletedAfter time.Time) ([]ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var restored []ShortURL
+	for _, id := range ids {
+		url, ok := s.urls[id]
+		if !ok || !url.IsDeleted || url.DeletedAt == nil || url.DeletedAt.Before(deletedAfter) {
+			continue
+		}
+		if !s.canEditLocked(url, userID) {
+			continue
+		}
+		url.IsDeleted = false
+		url.DeletedAt = nil
+		s.urls[id] = url
+		restored = append(restored, url)
+	}
+
+	return restored, nil
+}
+
+func (s *memory) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return len(s.purgeDeleted(deletedBefore)), nil
+}
+
+func (s *memory) purgeDeleted(deletedBefore time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []string
+	for id, url := range s.urls {
+		if url.IsDeleted && url.DeletedAt != nil && url.DeletedAt.Before(deletedBefore) {
+			s.removeURLLocked(id)
+			purged = append(purged, id)
+		}
+	}
+
+	return purged
+}
+
+func (s *memory) removeURLLocked(id string) {
+	url := s.urls[id]
+	delete(s.userURLs[url.UserID], id)
+	if s.canonicalURLs[url.CanonicalURL] == id {
+		delete(s.canonicalURLs, url.CanonicalURL)
+	}
+	delete(s.urls, id)
+	delete(s.revisions, id)
+	delete(s.clicks, id)
+	delete(s.linkChecks, id)
+	delete(s.urlTags, id)
+	for key := range s.rollups {
+		if key.urlID == id {
+			delete(s.rollups, key)
+		}
+	}
+}
+
+func (s *memory) FindByOrgID(ctx context.Context, orgID string) []ShortURL {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var urls []ShortURL
+
+	for _, url := range s.urls {
+		if url.OrgID == orgID {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+// canEditLocked reports whether userID owns url directly or through an organization.
+func (s *memory) canEditLocked(url ShortURL, userID string) bool {
+	if url.OrgID == "" {
+		return url.UserID == userID
+	}
+
+	member, ok := s.members[url.OrgID][userID]
+
+	return ok && member.Role.CanEdit()
+}
+
+func (s *memory) CreateUser(ctx context.Context, user User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Login == user.Login {
+			return User{}, ErrLoginTaken
+		}
+	}
+
+	s.users[user.ID] = user
+
+	return user, nil
+}
+
+func (s *memory) GetUserByID(ctx context.Context, id string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+
+	return user, nil
+}
+
+func (s *memory) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+
+	return User{}, ErrNotFound
+}
+
+func (s *memory) MergeUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	s.mergeUser(fromUserID, toUserID)
+
 	return nil
 }
+
+// userMerge lists changes made by mergeUser for the file storage to persist.
+type userMerge struct {
+	urls             []ShortURL
+	folders          []Folder
+	deletedFolders   []Folder
+	tags             []Tag
+	deletedTags      []Tag
+	retagged         []urlTagsRecord
+	templates        []UTMTemplate
+	deletedTemplates []UTMTemplate
+	webhooks         []Webhook
+}
+
+func (s *memory) mergeUser(fromUserID, toUserID string) userMerge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var m userMerge
+	folderIDs := make(map[string]string)
+	for id, folder := range s.folders {
+		if folder.UserID != fromUserID {
+			continue
+		}
+		if target, ok := s.findFolderLocked(toUserID, folder.Name); ok {
+			folderIDs[id] = target.ID
+			delete(s.folders, id)
+			m.deletedFolders = append(m.deletedFolders, folder)
+			continue
+		}
+		folder.UserID = toUserID
+		s.folders[id] = folder
+		m.folders = append(m.folders, folder)
+	}
+
+	tagIDs := make(map[string]string)
+	for id, tag := range s.tags {
+		if tag.UserID != fromUserID {
+			continue
+		}
+		if target, ok := s.findTagLocked(toUserID, tag.Name); ok {
+			tagIDs[id] = target.ID
+			delete(s.tags, id)
+			m.deletedTags = append(m.deletedTags, tag)
+			continue
+		}
+		tag.UserID = toUserID
+		s.tags[id] = tag
+		m.tags = append(m.tags, tag)
+	}
+
+	for id := range s.userURLs[fromUserID] {
+		url := s.urls[id]
+		url.UserID = toUserID
+		if folderID, ok := folderIDs[url.FolderID]; ok {
+			url.FolderID = folderID
+		}
+		s.setURLLocked(url)
+		m.urls = append(m.urls, url)
+
+		var add []string
+		for tagID := range s.urlTags[id] {
+			if target, ok := tagIDs[tagID]; ok {
+				delete(s.urlTags[id], tagID)
+				s.urlTags[id][target] = struct{}{}
+				add = append(add, target)
+			}
+		}
+		if len(add) > 0 {
+			m.retagged = append(m.retagged, urlTagsRecord{IDs: []string{id}, Add: add})
+		}
+	}
+
+	for key, tmpl := range s.utmTemplates {
+		if key.userID != fromUserID {
+			continue
+		}
+		delete(s.utmTemplates, key)
+		m.deletedTemplates = append(m.deletedTemplates, tmpl)
+		target := utmTemplateKey{userID: toUserID, name: tmpl.Name}
+		if _, ok := s.utmTemplates[target]; ok {
+			continue
+		}
+		tmpl.UserID = toUserID
+		s.utmTemplates[target] = tmpl
+		m.templates = append(m.templates, tmpl)
+	}
+
+	for id, hook := range s.webhooks {
+		if hook.UserID == fromUserID {
+			hook.UserID = toUserID
+			s.webhooks[id] = hook
+			m.webhooks = append(m.webhooks, hook)
+		}
+	}
+
+	return m
+}
+
+func (s *memory) SetPublicStats(ctx context.Context, id string, public bool) (ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok {
+		return ShortURL{}, ErrNotFound
+	}
+	url.PublicStats = public
+	s.urls[id] = url
+
+	return url, nil
+}
+
+func (s *memory) SetMetadata(ctx context.Context, id string, metadata LinkMetadata) (ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok {
+		return ShortURL{}, ErrNotFound
+	}
+	url.Metadata = &metadata
+	s.urls[id] = url
+
+	return url, nil
+}
+
+// putURL stores url as is, used to restore state from persistent storage.
+func (s *memory) putURL(url ShortURL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setURLLocked(url)
+	if id, err := strconv.Atoi(url.ID); err == nil && id > s.lastID {
+		s.lastID = id
+	}
+}
+
+func (s *memory) removeURL(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeURLLocked(id)
+}
+
+func (s *memory) putUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[user.ID] = user
+}
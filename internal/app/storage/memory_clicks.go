@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"sort"
+)
+
+func (s *memory) ConsumeClick(ctx context.Context, id string) (ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok {
+		return ShortURL{}, ErrNotFound
+	}
+	if url.MaxClicks == 0 {
+		return url, nil
+	}
+	if url.ClicksLeft <= 0 {
+		return url, ErrExhausted
+	}
+
+	url.ClicksLeft--
+	s.urls[id] = url
+
+	return url, nil
+}
+
+func (s *memory) RecordClick(ctx context.Context, click Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks[click.URLID] = append(s.clicks[click.URLID], click)
+	s.unrolledClicks = append(s.unrolledClicks, click)
+
+	return nil
+}
+
+func (s *memory) CountClicks(ctx context.Context, urlID string) ([]ClickCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var counts []ClickCount
+	index := make(map[ClickCount]int)
+	for _, click := range s.clicks[urlID] {
+		key := ClickCount{Variant: click.Variant, Country: click.Country, City: click.City, Bot: click.Bot}
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, key)
+		}
+		counts[i].Clicks++
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Variant != b.Variant {
+			return a.Variant < b.Variant
+		}
+		if a.Country != b.Country {
+			return a.Country < b.Country
+		}
+		if a.City != b.City {
+			return a.City < b.City
+		}
+		return !a.Bot && b.Bot
+	})
+
+	return counts, nil
+}
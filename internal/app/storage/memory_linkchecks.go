@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// maxLinkChecks bounds status history kept per link.
+const maxLinkChecks = 50
+
+func (s *memory) FindCheckableURLs(ctx context.Context, now time.Time) ([]ShortURL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var urls []ShortURL
+	for _, url := range s.urls {
+		if url.checkable(now) {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls, nil
+}
+
+func (s *memory) RecordLinkCheck(ctx context.Context, check LinkCheck) (ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[check.URLID]
+	if !ok {
+		return ShortURL{}, ErrNotFound
+	}
+	applyLinkCheck(&url, check)
+	s.urls[url.ID] = url
+
+	checks := append(s.linkChecks[url.ID], check)
+	if len(checks) > maxLinkChecks {
+		checks = checks[len(checks)-maxLinkChecks:]
+	}
+	s.linkChecks[url.ID] = checks
+
+	return url, nil
+}
+
+func (s *memory) FindLinkChecks(ctx context.Context, urlID string) ([]LinkCheck, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	checks := s.linkChecks[urlID]
+	found := make([]LinkCheck, 0, len(checks))
+	for i := len(checks) - 1; i >= 0; i-- {
+		found = append(found, checks[i])
+	}
+
+	return found, nil
+}
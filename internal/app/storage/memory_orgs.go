@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"sort"
+)
+
+func (s *memory) CreateOrg(ctx context.Context, org Org, ownerID string) (Org, error) {
+	s.putOrg(org)
+	s.putOrgMember(OrgMember{OrgID: org.ID, UserID: ownerID, Role: RoleOwner})
+
+	return org, nil
+}
+
+func (s *memory) GetOrg(ctx context.Context, id string) (Org, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	org, ok := s.orgs[id]
+	if !ok {
+		return Org{}, ErrNotFound
+	}
+
+	return org, nil
+}
+
+func (s *memory) FindUserOrgs(ctx context.Context, userID string) []UserOrg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orgs []UserOrg
+	for orgID, members := range s.members {
+		if member, ok := members[userID]; ok {
+			orgs = append(orgs, UserOrg{Org: s.orgs[orgID], Role: member.Role})
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Name < orgs[j].Name })
+
+	return orgs
+}
+
+func (s *memory) GetOrgMember(ctx context.Context, orgID, userID string) (OrgMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[orgID][userID]
+	if !ok {
+		return OrgMember{}, ErrNotFound
+	}
+
+	return member, nil
+}
+
+func (s *memory) FindOrgMembers(ctx context.Context, orgID string) []OrgMember {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := make([]OrgMember, 0, len(s.members[orgID]))
+	for _, member := range s.members[orgID] {
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+
+	return members
+}
+
+func (s *memory) SetOrgMember(ctx context.Context, member OrgMember) error {
+	if _, err := s.GetOrg(ctx, member.OrgID); err != nil {
+		return err
+	}
+	s.putOrgMember(member)
+
+	return nil
+}
+
+func (s *memory) RemoveOrgMember(ctx context.Context, orgID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.members[orgID][userID]; !ok {
+		return ErrNotFound
+	}
+	delete(s.members[orgID], userID)
+
+	return nil
+}
+
+func (s *memory) putOrg(org Org) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgs[org.ID] = org
+}
+
+func (s *memory) putOrgMember(member OrgMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.members[member.OrgID] == nil {
+		s.members[member.OrgID] = make(map[string]OrgMember)
+	}
+	s.members[member.OrgID][member.UserID] = member
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+func (s *memory) UpdateTarget(ctx context.Context, url ShortURL, editorID string) (ShortURL, error) {
+	updated, _, err := s.updateTarget(url, editorID)
+
+	return updated, err
+}
+
+func (s *memory) updateTarget(url ShortURL, editorID string) (ShortURL, Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.urls[url.ID]
+	if !ok {
+		return ShortURL{}, Revision{}, ErrNotFound
+	}
+	if u, ok := s.findDuplicateLocked(url); ok {
+		return u, Revision{}, ErrAlreadyExist
+	}
+
+	s.lastRevisionID++
+	rev := Revision{
+		ID:        s.lastRevisionID,
+		URLID:     current.ID,
+		LongURL:   current.LongURL,
+		EditorID:  editorID,
+		CreatedAt: time.Now(),
+	}
+	s.revisions[current.ID] = append(s.revisions[current.ID], rev)
+
+	current.LongURL = url.LongURL
+	current.CanonicalURL = url.CanonicalURL
+	s.setURLLocked(current)
+
+	return current, rev, nil
+}
+
+func (s *memory) FindRevisions(ctx context.Context, urlID string) []Revision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := make([]Revision, len(s.revisions[urlID]))
+	copy(revisions, s.revisions[urlID])
+
+	return revisions
+}
+
+func (s *memory) GetRevision(ctx context.Context, urlID string, revisionID int) (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rev := range s.revisions[urlID] {
+		if rev.ID == revisionID {
+			return rev, nil
+		}
+	}
+
+	return Revision{}, ErrNotFound
+}
+
+func (s *memory) putRevision(rev Revision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revisions[rev.URLID] = append(s.revisions[rev.URLID], rev)
+	if rev.ID > s.lastRevisionID {
+		s.lastRevisionID = rev.ID
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+type rollupKey struct {
+	urlID       string
+	granularity Granularity
+	bucket      int64
+}
+
+func (s *memory) FindClicks(ctx context.Context, urlID string, from, to time.Time) ([]Click, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clicks []Click
+	for _, click := range s.clicks[urlID] {
+		if !click.CreatedAt.Before(from) && click.CreatedAt.Before(to) {
+			clicks = append(clicks, click)
+		}
+	}
+	sort.SliceStable(clicks, func(i, j int) bool { return clicks[i].CreatedAt.Before(clicks[j].CreatedAt) })
+
+	return clicks, nil
+}
+
+// RollupClicks adds only clicks recorded since the previous run, so since is not used.
+func (s *memory) RollupClicks(ctx context.Context, since time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, click := range s.unrolledClicks {
+		if _, ok := s.urls[click.URLID]; !ok {
+			continue
+		}
+		for _, g := range []Granularity{GranularityHour, GranularityDay} {
+			bucket := g.Truncate(click.CreatedAt)
+			key := rollupKey{urlID: click.URLID, granularity: g, bucket: bucket.Unix()}
+			r, ok := s.rollups[key]
+			if !ok {
+				r = Rollup{URLID: click.URLID, Granularity: g, Bucket: bucket}
+			}
+			if click.Bot {
+				r.BotClicks++
+			} else {
+				r.Clicks++
+			}
+			s.rollups[key] = r
+		}
+	}
+	s.unrolledClicks = nil
+
+	return nil
+}
+
+func (s *memory) FindURLRollups(ctx context.Context, urlID string, granularity Granularity, from, to time.Time) ([]Rollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.findRollupsLocked(map[string]bool{urlID: true}, granularity, from, to), nil
+}
+
+func (s *memory) FindUserRollups(ctx context.Context, userID string, granularity Granularity, from, to time.Time) ([]Rollup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	urlIDs := make(map[string]bool)
+	for _, url := range s.urls {
+		if url.UserID == userID {
+			urlIDs[url.ID] = true
+		}
+	}
+
+	byBucket := make(map[int64]Rollup)
+	for _, r := range s.findRollupsLocked(urlIDs, granularity, from, to) {
+		sum, ok := byBucket[r.Bucket.Unix()]
+		if !ok {
+			sum = Rollup{Granularity: granularity, Bucket: r.Bucket}
+		}
+		sum.Clicks += r.Clicks
+		sum.BotClicks += r.BotClicks
+		byBucket[r.Bucket.Unix()] = sum
+	}
+
+	rollups := make([]Rollup, 0, len(byBucket))
+	for _, r := range byBucket {
+		rollups = append(rollups, r)
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Bucket.Before(rollups[j].Bucket) })
+
+	return rollups, nil
+}
+
+func (s *memory) findRollupsLocked(urlIDs map[string]bool, granularity Granularity, from, to time.Time) []Rollup {
+	var rollups []Rollup
+	for key, r := range s.rollups {
+		if key.granularity != granularity || !urlIDs[key.urlID] {
+			continue
+		}
+		if !r.Bucket.Before(from) && r.Bucket.Before(to) {
+			rollups = append(rollups, r)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if !rollups[i].Bucket.Equal(rollups[j].Bucket) {
+			return rollups[i].Bucket.Before(rollups[j].Bucket)
+		}
+		return rollups[i].URLID < rollups[j].URLID
+	})
+
+	return rollups
+}
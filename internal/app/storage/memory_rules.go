@@ -0,0 +1,31 @@
+package storage
+
+import "context"
+
+func (s *memory) SetRules(ctx context.Context, id string, rules RedirectRules) (ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok {
+		return ShortURL{}, ErrNotFound
+	}
+	url.Rules = rules
+	s.urls[id] = url
+
+	return url, nil
+}
+
+func (s *memory) SetVariants(ctx context.Context, id string, variants Variants) (ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok {
+		return ShortURL{}, ErrNotFound
+	}
+	url.Variants = variants
+	s.urls[id] = url
+
+	return url, nil
+}
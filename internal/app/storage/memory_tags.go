@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (s *memory) CreateFolder(ctx context.Context, folder Folder) (Folder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.folders {
+		if f.UserID == folder.UserID && f.Name == folder.Name {
+			return Folder{}, ErrNameTaken
+		}
+	}
+	if folder.CreatedAt.IsZero() {
+		folder.CreatedAt = time.Now()
+	}
+	s.folders[folder.ID] = folder
+
+	return folder, nil
+}
+
+func (s *memory) GetFolder(ctx context.Context, id string) (Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	folder, ok := s.folders[id]
+	if !ok {
+		return Folder{}, ErrNotFound
+	}
+
+	return folder, nil
+}
+
+func (s *memory) FindUserFolders(ctx context.Context, userID string) ([]Folder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var folders []Folder
+	for _, f := range s.folders {
+		if f.UserID == userID {
+			folders = append(folders, f)
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+
+	return folders, nil
+}
+
+func (s *memory) RenameFolder(ctx context.Context, userID, id, name string) (Folder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folder, ok := s.folders[id]
+	if !ok || folder.UserID != userID {
+		return Folder{}, ErrNotFound
+	}
+	for _, f := range s.folders {
+		if f.UserID == userID && f.Name == name && f.ID != id {
+			return Folder{}, ErrNameTaken
+		}
+	}
+	folder.Name = name
+	s.folders[id] = folder
+
+	return folder, nil
+}
+
+func (s *memory) DeleteFolder(ctx context.Context, userID, id string) error {
+	_, err := s.deleteFolder(userID, id)
+
+	return err
+}
+
+// deleteFolder returns links taken out of the deleted folder.
+func (s *memory) deleteFolder(userID, id string) ([]ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folder, ok := s.folders[id]
+	if !ok || folder.UserID != userID {
+		return nil, ErrNotFound
+	}
+	delete(s.folders, id)
+
+	var unfiled []ShortURL
+	for urlID, url := range s.urls {
+		if url.FolderID == id {
+			url.FolderID = ""
+			s.urls[urlID] = url
+			unfiled = append(unfiled, url)
+		}
+	}
+
+	return unfiled, nil
+}
+
+func (s *memory) MoveURLs(ctx context.Context, ids []string, folderID string) error {
+	_, err := s.moveURLs(ids, folderID)
+
+	return err
+}
+
+func (s *memory) moveURLs(ids []string, folderID string) ([]ShortURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.folders[folderID]; folderID != "" && !ok {
+		return nil, ErrNotFound
+	}
+
+	var moved []ShortURL
+	for _, id := range ids {
+		url, ok := s.urls[id]
+		if !ok || url.FolderID == folderID {
+			continue
+		}
+		url.FolderID = folderID
+		s.urls[id] = url
+		moved = append(moved, url)
+	}
+
+	return moved, nil
+}
+
+func (s *memory) CreateTag(ctx context.Context, tag Tag) (Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tags {
+		if t.UserID == tag.UserID && t.Name == tag.Name {
+			return Tag{}, ErrNameTaken
+		}
+	}
+	if tag.CreatedAt.IsZero() {
+		tag.CreatedAt = time.Now()
+	}
+	s.tags[tag.ID] = tag
+
+	return tag, nil
+}
+
+func (s *memory) GetTag(ctx context.Context, id string) (Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tag, ok := s.tags[id]
+	if !ok {
+		return Tag{}, ErrNotFound
+	}
+
+	return tag, nil
+}
+
+func (s *memory) FindUserTags(ctx context.Context, userID string) ([]Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tags []Tag
+	for _, t := range s.tags {
+		if t.UserID == userID {
+			tags = append(tags, t)
+		}
+	}
+	sortTags(tags)
+
+	return tags, nil
+}
+
+func (s *memory) RenameTag(ctx context.Context, userID, id, name string) (Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag, ok := s.tags[id]
+	if !ok || tag.UserID != userID {
+		return Tag{}, ErrNotFound
+	}
+	for _, t := range s.tags {
+		if t.UserID == userID && t.Name == name && t.ID != id {
+			return Tag{}, ErrNameTaken
+		}
+	}
+	tag.Name = name
+	s.tags[id] = tag
+
+	return tag, nil
+}
+
+func (s *memory) DeleteTag(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag, ok := s.tags[id]
+	if !ok || tag.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.tags, id)
+	for _, tagIDs := range s.urlTags {
+		delete(tagIDs, id)
+	}
+
+	return nil
+}
+
+func (s *memory) TagURLs(ctx context.Context, ids, add, remove []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tagID := range add {
+		if _, ok := s.tags[tagID]; !ok {
+			return ErrNotFound
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := s.urls[id]; !ok {
+			continue
+		}
+		tagIDs, ok := s.urlTags[id]
+		if !ok {
+			tagIDs = make(map[string]struct{})
+			s.urlTags[id] = tagIDs
+		}
+		for _, tagID := range add {
+			tagIDs[tagID] = struct{}{}
+		}
+		for _, tagID := range remove {
+			delete(tagIDs, tagID)
+		}
+	}
+
+	return nil
+}
+
+func (s *memory) FindURLTags(ctx context.Context, userID string, ids []string) (map[string][]Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := make(map[string][]Tag)
+	for _, id := range ids {
+		var tags []Tag
+		for tagID := range s.urlTags[id] {
+			if tag, ok := s.tags[tagID]; ok && tag.UserID == userID {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > 0 {
+			sortTags(tags)
+			found[id] = tags
+		}
+	}
+
+	return found, nil
+}
+
+func (s *memory) FindURLs(ctx context.Context, filter URLFilter) ([]ShortURL, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var urls []ShortURL
+	if filter.OrgID != "" {
+		for _, url := range s.urls {
+			if url.OrgID == filter.OrgID && s.matchesLocked(url, filter) {
+				urls = append(urls, url)
+			}
+		}
+		return urls, nil
+	}
+	for id := range s.userURLs[filter.UserID] {
+		if url := s.urls[id]; s.matchesLocked(url, filter) {
+			urls = append(urls, url)
+		}
+	}
+
+	return urls, nil
+}
+
+func (s *memory) matchesLocked(url ShortURL, filter URLFilter) bool {
+	if filter.FolderID != "" && url.FolderID != filter.FolderID {
+		return false
+	}
+	for _, tagID := range filter.TagIDs {
+		if tag, ok := s.tags[tagID]; !ok || tag.UserID != filter.UserID {
+			return false
+		}
+		if _, ok := s.urlTags[url.ID][tagID]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *memory) findFolderLocked(userID, name string) (Folder, bool) {
+	for _, f := range s.folders {
+		if f.UserID == userID && f.Name == name {
+			return f, true
+		}
+	}
+
+	return Folder{}, false
+}
+
+func (s *memory) findTagLocked(userID, name string) (Tag, bool) {
+	for _, t := range s.tags {
+		if t.UserID == userID && t.Name == name {
+			return t, true
+		}
+	}
+
+	return Tag{}, false
+}
+
+func sortTags(tags []Tag) {
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+}
+
+// putFolder and putTag store entities as is, used to restore state from persistent storage.
+func (s *memory) putFolder(folder Folder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.folders[folder.ID] = folder
+}
+
+func (s *memory) putTag(tag Tag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tags[tag.ID] = tag
+}
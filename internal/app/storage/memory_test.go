@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,8 +20,10 @@ func TestMemory_Create(t *testing.T) {
 		{
 			name: "add first url",
 			storage: &memory{
-				urls: map[string]ShortURL{},
-				mu:   new(sync.RWMutex),
+				urls:          map[string]ShortURL{},
+				userURLs:      map[string]map[string]struct{}{},
+				canonicalURLs: map[string]string{},
+				mu:            new(sync.RWMutex),
 			},
 			url:      ShortURL{LongURL: "https://example.com/very/long/url/for/shortener"},
 			expectID: "1",
@@ -34,8 +37,10 @@ func TestMemory_Create(t *testing.T) {
 						LongURL: "https://example.com/added/long/url",
 					},
 				},
-				lastID: 1,
-				mu:     new(sync.RWMutex),
+				userURLs:      map[string]map[string]struct{}{},
+				canonicalURLs: map[string]string{},
+				lastID:        1,
+				mu:            new(sync.RWMutex),
 			},
 			url:      ShortURL{LongURL: "https://example.com/very/long/url/for/shortener"},
 			expectID: "2",
@@ -43,8 +48,10 @@ func TestMemory_Create(t *testing.T) {
 		{
 			name: "add url with custom ID",
 			storage: &memory{
-				urls: map[string]ShortURL{},
-				mu:   new(sync.RWMutex),
+				urls:          map[string]ShortURL{},
+				userURLs:      map[string]map[string]struct{}{},
+				canonicalURLs: map[string]string{},
+				mu:            new(sync.RWMutex),
 			},
 			url: ShortURL{
 				ID:      "custom",
@@ -64,6 +71,54 @@ func TestMemory_Create(t *testing.T) {
 	}
 }
 
+func TestMemory_CreateBatch(t *testing.T) {
+	s := newMemory()
+	ctx := context.Background()
+	_, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/a", CanonicalURL: "https://example.com/a"})
+	require.NoError(t, err)
+
+	urls, err := s.CreateBatch(ctx, []ShortURL{
+		{LongURL: "https://example.com/a", CanonicalURL: "https://example.com/a", CorrelationID: "dup"},
+		{LongURL: "https://example.com/b", CanonicalURL: "https://example.com/b", CorrelationID: "new"},
+	}, Quota{})
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "dup", urls[0].CorrelationID)
+	assert.False(t, urls[0].Inserted)
+	assert.Equal(t, "new", urls[1].CorrelationID)
+	assert.True(t, urls[1].Inserted)
+}
+
+func TestMemory_CanonicalIndex(t *testing.T) {
+	s := newMemory()
+	ctx := context.Background()
+	a, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/a", CanonicalURL: "https://example.com/a", UserID: "u"})
+	require.NoError(t, err)
+	b, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/b", CanonicalURL: "https://example.com/b", UserID: "u"})
+	require.NoError(t, err)
+
+	_, err = s.Create(ctx, ShortURL{LongURL: "https://example.com/a?", CanonicalURL: "https://example.com/a"})
+	assert.ErrorIs(t, err, ErrAlreadyExist)
+	c, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/c", CanonicalURL: "https://example.com/c"})
+	require.NoError(t, err)
+	assert.Equal(t, "3", c.ID)
+
+	b.LongURL, b.CanonicalURL = "https://example.com/a", "https://example.com/a"
+	_, err = s.UpdateTarget(ctx, b, "u")
+	assert.ErrorIs(t, err, ErrAlreadyExist)
+
+	a.LongURL, a.CanonicalURL = "https://example.com/d", "https://example.com/d"
+	_, err = s.UpdateTarget(ctx, a, "u")
+	require.NoError(t, err)
+	_, err = s.UpdateTarget(ctx, b, "u")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"https://example.com/a": b.ID,
+		"https://example.com/c": c.ID,
+		"https://example.com/d": a.ID,
+	}, s.canonicalURLs)
+}
+
 func TestMemory_GetByID(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -111,3 +166,100 @@ func TestMemory_GetByID(t *testing.T) {
 		})
 	}
 }
+
+func TestMemory_RestoreAndPurge(t *testing.T) {
+	ctx := context.Background()
+	s := newMemory()
+	for _, id := range []string{"old", "recent", "foreign"} {
+		_, err := s.Create(ctx, ShortURL{ID: id, LongURL: "https://example.com/" + id, UserID: "user"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.DeleteBatch(ctx, "user", []string{"old", "recent", "foreign"}))
+
+	deletedAt := time.Now().Add(-48 * time.Hour)
+	old := s.urls["old"]
+	old.DeletedAt = &deletedAt
+	s.urls["old"] = old
+
+	restored, err := s.RestoreBatch(ctx, "other", []string{"foreign"}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	restored, err = s.RestoreBatch(ctx, "user", []string{"old", "recent"}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+	assert.Equal(t, "recent", restored[0].ID)
+	assert.False(t, s.urls["recent"].IsDeleted)
+	assert.Nil(t, s.urls["recent"].DeletedAt)
+
+	purged, err := s.PurgeDeleted(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = s.GetByID(ctx, "old")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetByID(ctx, "foreign")
+	assert.NoError(t, err)
+}
+
+func TestMemory_RollupClicks(t *testing.T) {
+	ctx := context.Background()
+	s := newMemory()
+	a, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/a", UserID: "user"})
+	require.NoError(t, err)
+	b, err := s.Create(ctx, ShortURL{LongURL: "https://example.com/b", UserID: "user"})
+	require.NoError(t, err)
+
+	day := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []Click{
+		{URLID: a.ID, CreatedAt: day.Add(10*time.Hour + 5*time.Minute)},
+		{URLID: a.ID, CreatedAt: day.Add(10*time.Hour + 50*time.Minute)},
+		{URLID: a.ID, CreatedAt: day.Add(11 * time.Hour), Bot: true},
+		{URLID: b.ID, CreatedAt: day.Add(10 * time.Hour)},
+		{URLID: a.ID, CreatedAt: day.Add(30 * time.Hour)},
+	} {
+		require.NoError(t, s.RecordClick(ctx, c))
+	}
+
+	require.NoError(t, s.RollupClicks(ctx, time.Time{}))
+	// Recomputing the last day must not count clicks twice.
+	require.NoError(t, s.RollupClicks(ctx, day.Add(24*time.Hour)))
+
+	hourly, err := s.FindURLRollups(ctx, a.ID, GranularityHour, day, day.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []Rollup{
+		{URLID: a.ID, Granularity: GranularityHour, Bucket: day.Add(10 * time.Hour), Clicks: 2},
+		{URLID: a.ID, Granularity: GranularityHour, Bucket: day.Add(11 * time.Hour), BotClicks: 1},
+	}, hourly)
+
+	daily, err := s.FindUserRollups(ctx, "user", GranularityDay, day, day.Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []Rollup{
+		{Granularity: GranularityDay, Bucket: day, Clicks: 3, BotClicks: 1},
+		{Granularity: GranularityDay, Bucket: day.Add(24 * time.Hour), Clicks: 1},
+	}, daily)
+}
+
+func TestMemory_FindCheckableURLs(t *testing.T) {
+	ctx := context.Background()
+	s := newMemory()
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	for _, u := range []ShortURL{
+		{ID: "active", LongURL: "https://example.com/active"},
+		{ID: "deleted", LongURL: "https://example.com/deleted", IsDeleted: true},
+		{ID: "scheduled", LongURL: "https://example.com/scheduled", NotBefore: &future},
+		{ID: "expired", LongURL: "https://example.com/expired", NotAfter: &past},
+		{ID: "exhausted", LongURL: "https://example.com/exhausted", MaxClicks: 1},
+		{ID: "one-time", LongURL: "https://example.com/one-time", MaxClicks: 1, ClicksLeft: 1},
+		{ID: "protected", LongURL: "https://example.com/protected", PasswordHash: "hash"},
+	} {
+		_, err := s.Create(ctx, u)
+		require.NoError(t, err)
+	}
+
+	urls, err := s.FindCheckableURLs(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "active", urls[0].ID)
+}
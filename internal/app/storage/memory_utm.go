@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"sort"
+)
+
+type utmTemplateKey struct {
+	userID string
+	name   string
+}
+
+func (s *memory) SaveUTMTemplate(ctx context.Context, tmpl UTMTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.utmTemplates[utmTemplateKey{userID: tmpl.UserID, name: tmpl.Name}] = tmpl
+
+	return nil
+}
+
+func (s *memory) GetUTMTemplate(ctx context.Context, userID, name string) (UTMTemplate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmpl, ok := s.utmTemplates[utmTemplateKey{userID: userID, name: name}]
+	if !ok {
+		return UTMTemplate{}, ErrNotFound
+	}
+
+	return tmpl, nil
+}
+
+func (s *memory) FindUTMTemplates(ctx context.Context, userID string) []UTMTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var templates []UTMTemplate
+	for key, tmpl := range s.utmTemplates {
+		if key.userID == userID {
+			templates = append(templates, tmpl)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	return templates
+}
+
+func (s *memory) DeleteUTMTemplate(ctx context.Context, userID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := utmTemplateKey{userID: userID, name: name}
+	if _, ok := s.utmTemplates[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.utmTemplates, key)
+
+	return nil
+}
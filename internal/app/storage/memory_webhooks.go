@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (s *memory) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hook.CreatedAt.IsZero() {
+		hook.CreatedAt = time.Now()
+	}
+	s.webhooks[hook.ID] = hook
+
+	return hook, nil
+}
+
+func (s *memory) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hook, ok := s.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrNotFound
+	}
+
+	return hook, nil
+}
+
+func (s *memory) FindUserWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hooks []Webhook
+	for _, hook := range s.webhooks {
+		if hook.UserID == userID {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+
+	return hooks, nil
+}
+
+func (s *memory) DeleteWebhook(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook, ok := s.webhooks[id]
+	if !ok || hook.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.webhooks, id)
+	for deliveryID, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+func (s *memory) SaveDelivery(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[d.WebhookID]; !ok {
+		return ErrNotFound
+	}
+	s.deliveries[d.ID] = d
+
+	return nil
+}
+
+func (s *memory) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Delivery
+	for _, d := range s.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].NextAttemptAt = now.Add(lease)
+		s.deliveries[due[i].ID] = due[i]
+	}
+
+	return due, nil
+}
+
+func (s *memory) PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	return len(s.purgeDeliveries(finishedBefore)), nil
+}
+
+func (s *memory) purgeDeliveries(finishedBefore time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []string
+	for id, d := range s.deliveries {
+		delivered := d.Status == DeliveryDelivered && d.DeliveredAt != nil && d.DeliveredAt.Before(finishedBefore)
+		dead := d.Status == DeliveryDead && d.CreatedAt.Before(finishedBefore)
+		if delivered || dead {
+			delete(s.deliveries, id)
+			purged = append(purged, id)
+		}
+	}
+
+	return purged
+}
+
+// removeDelivery removes a delivery, used to restore state from persistent storage.
+func (s *memory) removeDelivery(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.deliveries, id)
+}
+
+func (s *memory) FindDeliveries(ctx context.Context, webhookID, status string) ([]Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found []Delivery
+	for _, d := range s.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			found = append(found, d)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].CreatedAt.After(found[j].CreatedAt) })
+
+	return found, nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// LinkMetadata describes the destination page of a link, fetched after creation.
+type LinkMetadata struct {
+	Title       string
+	Description string
+	Favicon     string
+	Image       string
+	FetchedAt   time.Time
+}
+
+func (m LinkMetadata) Value() (driver.Value, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func (m *LinkMetadata) Scan(src interface{}) error {
+	return scanJSON(src, m)
+}
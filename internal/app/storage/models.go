@@ -1,9 +1,205 @@
 package storage
 
+import "time"
+
 type ShortURL struct {
-	ID            string `db:"id"`
-	LongURL       string `db:"url"`
-	UserID        string `db:"user_id"`
-	CorrelationID string `db:"correlation_id"`
-	IsDeleted     bool   `db:"is_deleted"`
+	ID            string        `db:"id"`
+	LongURL       string        `db:"url"`
+	CanonicalURL  string        `db:"canonical_url"`
+	UserID        string        `db:"user_id"`
+	CorrelationID string        `db:"correlation_id"`
+	IsDeleted     bool          `db:"is_deleted"`
+	DeletedAt     *time.Time    `db:"deleted_at"`
+	OrgID         string        `db:"org_id"`
+	CreatedAt     time.Time     `db:"created_at"`
+	RedirectType  int           `db:"redirect_type"`
+	Passthrough   string        `db:"passthrough"`
+	UTMTemplate   string        `db:"utm_template"`
+	PasswordHash  string        `db:"password_hash"`
+	MaxClicks     int           `db:"max_clicks"`
+	ClicksLeft    int           `db:"clicks_left"`
+	NotBefore     *time.Time    `db:"not_before"`
+	NotAfter      *time.Time    `db:"not_after"`
+	Rules         RedirectRules `db:"rules"`
+	Variants      Variants      `db:"variants"`
+	PublicStats   bool          `db:"public_stats"`
+	Metadata      *LinkMetadata `db:"metadata"`
+	// LinkStatus is the destination status code seen by the last check,
+	// LinkFailures counts consecutive failed checks.
+	LinkStatus    int        `db:"link_status"`
+	LinkFailures  int        `db:"link_failures"`
+	LinkCheckedAt *time.Time `db:"link_checked_at"`
+	FolderID      string     `db:"folder_id"`
+	// Inserted is set by CreateBatch on links it has created, it is not stored.
+	Inserted bool `db:"-" json:"-"`
+}
+
+// HasOptions reports whether the link behaves differently from a plain redirect
+// to its destination. Only plain links are deduplicated, a link with options
+// must not be answered with another link lacking them.
+func (u ShortURL) HasOptions() bool {
+	return u.RedirectType != 0 || u.Passthrough != "" || u.UTMTemplate != "" || u.PasswordHash != "" ||
+		u.MaxClicks > 0 || u.NotBefore != nil || u.NotAfter != nil || len(u.Rules) > 0 || len(u.Variants) > 0
+}
+
+// Click is a redirect served by a link, Variant is set for split links.
+type Click struct {
+	URLID     string    `db:"url_id"`
+	Variant   string    `db:"variant"`
+	Country   string    `db:"country"`
+	City      string    `db:"city"`
+	Bot       bool      `db:"is_bot"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type Granularity string
+
+const (
+	GranularityHour Granularity = "hour"
+	GranularityDay  Granularity = "day"
+)
+
+func (g Granularity) Valid() bool {
+	return g == GranularityHour || g == GranularityDay
+}
+
+// Truncate returns the start of the UTC bucket t falls into.
+func (g Granularity) Truncate(t time.Time) time.Time {
+	if g == GranularityHour {
+		return t.UTC().Truncate(time.Hour)
+	}
+
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Rollup counts clicks of a link within the bucket starting at Bucket.
+type Rollup struct {
+	URLID       string      `db:"url_id"`
+	Granularity Granularity `db:"granularity"`
+	Bucket      time.Time   `db:"bucket"`
+	Clicks      int         `db:"clicks"`
+	BotClicks   int         `db:"bot_clicks"`
+}
+
+type ClickCount struct {
+	Variant string `db:"variant"`
+	Country string `db:"country"`
+	City    string `db:"city"`
+	Bot     bool   `db:"is_bot"`
+	Clicks  int    `db:"clicks"`
+}
+
+// Revision keeps a previous target of a short URL replaced by EditorID at CreatedAt.
+type Revision struct {
+	ID        int       `db:"id"`
+	URLID     string    `db:"url_id"`
+	LongURL   string    `db:"url"`
+	EditorID  string    `db:"editor_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type URLCounts struct {
+	Active       int `db:"active"`
+	CreatedSince int `db:"created_since"`
+}
+
+// Quota caps links of one user, zero values mean no limit. Total counts links
+// not deleted, Daily counts links created since DayStart.
+type Quota struct {
+	Total    int
+	Daily    int
+	DayStart time.Time
+}
+
+func (q Quota) Enabled() bool {
+	return q.Total > 0 || q.Daily > 0
+}
+
+// check returns an error when n more links exceed the quota given current counts.
+func (q Quota) check(counts URLCounts, n int) error {
+	if q.Total > 0 && counts.Active+n > q.Total {
+		return ErrTotalQuota
+	}
+	if q.Daily > 0 && counts.CreatedSince+n > q.Daily {
+		return ErrDailyQuota
+	}
+
+	return nil
+}
+
+type User struct {
+	ID           string `db:"id"`
+	Login        string `db:"login"`
+	PasswordHash string `db:"password_hash"`
+}
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+func (r Role) CanManage() bool {
+	return r == RoleOwner
+}
+
+type Org struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+type OrgMember struct {
+	OrgID  string `db:"org_id"`
+	UserID string `db:"user_id"`
+	Role   Role   `db:"role"`
+}
+
+type UserOrg struct {
+	Org
+	Role Role `db:"role"`
+}
+
+// Folder groups links of a user, a link is in one folder at most.
+type Folder struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Tag labels links of a user, a link may have many tags.
+type Tag struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// URLFilter selects links of UserID or, if OrgID is set, of the organization,
+// which are in FolderID if set and have all tags of UserID with TagIDs.
+type URLFilter struct {
+	UserID   string
+	OrgID    string
+	FolderID string
+	TagIDs   []string
+}
+
+type UTMTemplate struct {
+	UserID   string `db:"user_id"`
+	Name     string `db:"name"`
+	Source   string `db:"source"`
+	Medium   string `db:"medium"`
+	Campaign string `db:"campaign"`
+	Term     string `db:"term"`
+	Content  string `db:"content"`
 }
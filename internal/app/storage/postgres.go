@@ -10,6 +10,19 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+const urlColumns = "id, url, coalesce(canonical_url, '') as canonical_url, user_id, correlation_id, is_deleted, deleted_at, coalesce(cast(org_id as text), '') as org_id, created_at, redirect_type, passthrough, utm_template, password_hash, max_clicks, clicks_left, not_before, not_after, rules, variants, public_stats, metadata, link_status, link_failures, link_checked_at, coalesce(cast(folder_id as text), '') as folder_id"
+
+// plainURLCondition matches links without options, see ShortURL.HasOptions,
+// unique indexes on url and canonical_url cover only them.
+const plainURLCondition = "redirect_type = 0 and passthrough = '' and utm_template = '' and password_hash = '' and max_clicks = 0 and not_before is null and not_after is null and rules = '[]' and variants = '[]'"
+
+const findDuplicateQuery = "select " + urlColumns + " from urls where (url = $1 or canonical_url = nullif($2, '')) and " + plainURLCondition + " limit 1"
+
+const countUserURLsQuery = `select count(*) filter (where not is_deleted) as active,
+       count(*) filter (where created_at >= $2) as created_since
+from urls
+where user_id = $1`
+
 type deleteMessage struct {
 	userID string
 	urls   []string
@@ -21,7 +34,7 @@ type postgres struct {
 	deleteCh chan deleteMessage
 }
 
-func NewPostgresStorage(ctx context.Context, db *sqlx.DB, timeout time.Duration) (URLStorage, error) {
+func NewPostgresStorage(ctx context.Context, db *sqlx.DB, timeout time.Duration) (Storage, error) {
 	p := postgres{
 		db:       db,
 		timeout:  timeout,
@@ -48,7 +61,10 @@ func (s *postgres) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
 
 	rows, err := s.db.NamedQueryContext(
 		ctx,
-		"insert into urls (url, user_id, correlation_id) values (:url, :user_id, :correlation_id) on conflict on constraint urls_url_key do nothing returning id",
+		`insert into urls (url, canonical_url, user_id, correlation_id, org_id, redirect_type, passthrough, utm_template, password_hash, max_clicks, clicks_left, not_before, not_after, rules, variants)
+values (:url, nullif(:canonical_url, ''), :user_id, :correlation_id, cast(nullif(:org_id, '') as uuid), :redirect_type, :passthrough, :utm_template, :password_hash, :max_clicks, :clicks_left, :not_before, :not_after, :rules, :variants)
+on conflict do nothing
+returning id, created_at`,
 		&url,
 	)
 	defer func() { _ = rows.Close() }()
@@ -56,7 +72,7 @@ func (s *postgres) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
 		return ShortURL{}, fmt.Errorf("insert url to DB: %w", err)
 	}
 	if rows.Next() {
-		err = rows.Scan(&url.ID)
+		err = rows.Scan(&url.ID, &url.CreatedAt)
 		if err != nil {
 			return ShortURL{}, fmt.Errorf("get inserted url id: %w", err)
 		}
@@ -67,12 +83,7 @@ func (s *postgres) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
 		return ShortURL{}, fmt.Errorf("insert url to DB: %w", err)
 	}
 
-	err = s.db.GetContext(
-		ctx,
-		&url,
-		"select id, url, user_id, correlation_id from urls where url = $1 limit 1",
-		url.LongURL,
-	)
+	err = s.db.GetContext(ctx, &url, findDuplicateQuery, url.LongURL, url.CanonicalURL)
 	if err != nil {
 		return ShortURL{}, fmt.Errorf("get duplicated url: %w", err)
 	}
@@ -80,12 +91,44 @@ func (s *postgres) Create(ctx context.Context, url ShortURL) (ShortURL, error) {
 	return url, ErrAlreadyExist
 }
 
+// checkQuota locks creation of links by their owner until tx ends, so concurrent
+// requests cannot pass the quota together, and checks new links fit into it.
+func checkQuota(ctx context.Context, tx *sqlx.Tx, urls []ShortURL, quota Quota) error {
+	userID := urls[0].UserID
+	if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(hashtext($1))", userID); err != nil {
+		return fmt.Errorf("lock user urls: %w", err)
+	}
+
+	n := 0
+	for _, u := range urls {
+		if u.HasOptions() {
+			n++
+			continue
+		}
+		var found bool
+		err := tx.GetContext(ctx, &found, "select exists (select 1 from urls where (url = $1 or canonical_url = nullif($2, '')) and "+plainURLCondition+")", u.LongURL, u.CanonicalURL)
+		if err != nil {
+			return fmt.Errorf("find duplicate url: %w", err)
+		}
+		if !found {
+			n++
+		}
+	}
+
+	var counts URLCounts
+	if err := tx.GetContext(ctx, &counts, countUserURLsQuery, userID, quota.DayStart); err != nil {
+		return fmt.Errorf("count user urls: %w", err)
+	}
+
+	return quota.check(counts, n)
+}
+
 func (s *postgres) GetByID(ctx context.Context, id string) (ShortURL, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
 	var url ShortURL
-	err := s.db.GetContext(ctx, &url, "select id, url, user_id, correlation_id, is_deleted from urls where id = $1", id)
+	err := s.db.GetContext(ctx, &url, "select "+urlColumns+" from urls where id = $1", id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ShortURL{}, ErrNotFound
@@ -105,7 +148,7 @@ func (s *postgres) FindByUserID(ctx context.Context, userID string) []ShortURL {
 	err := s.db.SelectContext(
 		ctx,
 		&urls,
-		"select id, url, user_id, correlation_id from urls where user_id = $1",
+		"select "+urlColumns+" from urls where user_id = $1",
 		userID,
 	)
 	if err != nil {
@@ -115,7 +158,39 @@ func (s *postgres) FindByUserID(ctx context.Context, userID string) []ShortURL {
 	return urls
 }
 
-func (s *postgres) CreateBatch(ctx context.Context, urls []ShortURL) ([]ShortURL, error) {
+func (s *postgres) FindByOrgID(ctx context.Context, orgID string) []ShortURL {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var urls []ShortURL
+
+	err := s.db.SelectContext(
+		ctx,
+		&urls,
+		"select "+urlColumns+" from urls where org_id = $1",
+		orgID,
+	)
+	if err != nil {
+		return nil
+	}
+
+	return urls
+}
+
+func (s *postgres) CountUserURLs(ctx context.Context, userID string, since time.Time) (URLCounts, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var counts URLCounts
+	err := s.db.GetContext(ctx, &counts, countUserURLsQuery, userID, since)
+	if err != nil {
+		return URLCounts{}, fmt.Errorf("count user urls: %w", err)
+	}
+
+	return counts, nil
+}
+
+func (s *postgres) CreateBatch(ctx context.Context, urls []ShortURL, quota Quota) ([]ShortURL, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
@@ -125,9 +200,18 @@ func (s *postgres) CreateBatch(ctx context.Context, urls []ShortURL) ([]ShortURL
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	if quota.Enabled() && len(urls) > 0 {
+		if err := checkQuota(ctx, tx, urls, quota); err != nil {
+			return nil, err
+		}
+	}
+
 	stmt, err := tx.PreparexContext(
 		ctx,
-		"insert into urls (url, user_id, correlation_id) values ($1, $2, $3) returning id",
+		`insert into urls (url, canonical_url, user_id, correlation_id, org_id, redirect_type, passthrough, utm_template, password_hash, max_clicks, clicks_left, not_before, not_after, rules, variants)
+values ($1, nullif($2, ''), $3, $4, nullif($5, '')::uuid, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+on conflict do nothing
+returning id, created_at`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("prepare stmt: %w", err)
@@ -139,9 +223,27 @@ func (s *postgres) CreateBatch(ctx context.Context, urls []ShortURL) ([]ShortURL
 		err := stmt.QueryRowxContext(
 			ctx,
 			u.LongURL,
+			u.CanonicalURL,
 			u.UserID,
 			u.CorrelationID,
-		).Scan(&u.ID)
+			u.OrgID,
+			u.RedirectType,
+			u.Passthrough,
+			u.UTMTemplate,
+			u.PasswordHash,
+			u.MaxClicks,
+			u.ClicksLeft,
+			u.NotBefore,
+			u.NotAfter,
+			u.Rules,
+			u.Variants,
+		).Scan(&u.ID, &u.CreatedAt)
+		u.Inserted = err == nil
+		if errors.Is(err, sql.ErrNoRows) {
+			correlationID := u.CorrelationID
+			err = tx.GetContext(ctx, &u, findDuplicateQuery, u.LongURL, u.CanonicalURL)
+			u.CorrelationID = correlationID
+		}
 		if err != nil {
 			return nil, fmt.Errorf("insert url to DB: %w", err)
 		}
@@ -165,6 +267,54 @@ func (s *postgres) DeleteBatch(ctx context.Context, userID string, ids []string)
 	return nil
 }
 
+func (s *postgres) RestoreBatch(ctx context.Context, userID string, ids []string, deletedAfter time.Time) ([]ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	arg := map[string]interface{}{
+		"userID":       userID,
+		"urls":         ids,
+		"deletedAfter": deletedAfter,
+	}
+	query, args, err := sqlx.Named(`update urls set is_deleted = false, deleted_at = null
+where id in (:urls) and is_deleted and deleted_at >= :deletedAfter and (
+    (org_id is null and user_id = :userID) or
+    org_id in (select org_id from org_members where user_id = :userID and role in ('owner', 'editor'))
+)
+returning `+urlColumns, arg)
+	if err != nil {
+		return nil, fmt.Errorf("prepare query: %w", err)
+	}
+	query, args, err = sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("prepare query: %w", err)
+	}
+	query = s.db.Rebind(query)
+
+	var restored []ShortURL
+	if err := s.db.SelectContext(ctx, &restored, query, args...); err != nil {
+		return nil, fmt.Errorf("restore urls: %w", err)
+	}
+
+	return restored, nil
+}
+
+func (s *postgres) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "delete from urls where is_deleted and deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted urls: %w", err)
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted urls: %w", err)
+	}
+
+	return int(purged), nil
+}
+
 func (s *postgres) deleteBatch(ctx context.Context, userID string, ids []string) error {
 	ctx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
@@ -179,7 +329,11 @@ func (s *postgres) deleteBatch(ctx context.Context, userID string, ids []string)
 		"userID": userID,
 		"urls":   ids,
 	}
-	query, args, err := sqlx.Named("update urls set is_deleted = true where user_id = :userID and id in (:urls)", arg)
+	query, args, err := sqlx.Named(`update urls set is_deleted = true, deleted_at = now()
+where id in (:urls) and not is_deleted and (
+    (org_id is null and user_id = :userID) or
+    org_id in (select org_id from org_members where user_id = :userID and role in ('owner', 'editor'))
+)`, arg)
 	if err != nil {
 		return fmt.Errorf("prepare query: %w", err)
 	}
@@ -206,3 +360,129 @@ func (s *postgres) deleteBatch(ctx context.Context, userID string, ids []string)
 
 	return nil
 }
+
+func (s *postgres) CreateUser(ctx context.Context, user User) (User, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.NamedExecContext(
+		ctx,
+		"insert into users (id, login, password_hash) values (:id, :login, :password_hash) on conflict (login) do nothing",
+		&user,
+	)
+	if err != nil {
+		return User{}, fmt.Errorf("insert user to DB: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return User{}, fmt.Errorf("insert user to DB: %w", err)
+	}
+	if affected == 0 {
+		return User{}, ErrLoginTaken
+	}
+
+	return user, nil
+}
+
+func (s *postgres) GetUserByID(ctx context.Context, id string) (User, error) {
+	return s.getUser(ctx, "select id, login, password_hash from users where id = $1", id)
+}
+
+func (s *postgres) GetUserByLogin(ctx context.Context, login string) (User, error) {
+	return s.getUser(ctx, "select id, login, password_hash from users where login = $1", login)
+}
+
+func (s *postgres) getUser(ctx context.Context, query string, arg string) (User, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var user User
+	err := s.db.GetContext(ctx, &user, query, arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("get user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *postgres) MergeUserURLs(ctx context.Context, fromUserID, toUserID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("create tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, query := range mergeUserQueries {
+		if _, err := tx.ExecContext(ctx, query, toUserID, fromUserID); err != nil {
+			return fmt.Errorf("merge user: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// mergeUserQueries move everything of the user $2 to the user $1, links are moved
+// to folders and tags of $1 named like ones of $2 before those are deleted.
+var mergeUserQueries = []string{
+	"update urls set user_id = $1 where user_id = $2",
+	`update urls u set folder_id = t.id
+from folders f
+         join folders t on t.user_id = $1 and t.name = f.name
+where f.user_id = $2 and u.folder_id = f.id`,
+	"delete from folders f using folders t where f.user_id = $2 and t.user_id = $1 and t.name = f.name",
+	"update folders set user_id = $1 where user_id = $2",
+	`insert into url_tags (url_id, tag_id)
+select ut.url_id, t.id
+from url_tags ut
+         join tags f on f.id = ut.tag_id
+         join tags t on t.user_id = $1 and t.name = f.name
+where f.user_id = $2
+on conflict do nothing`,
+	"delete from tags f using tags t where f.user_id = $2 and t.user_id = $1 and t.name = f.name",
+	"update tags set user_id = $1 where user_id = $2",
+	"delete from utm_templates f using utm_templates t where f.user_id = $2 and t.user_id = $1 and t.name = f.name",
+	"update utm_templates set user_id = $1 where user_id = $2",
+	"update webhooks set user_id = $1 where user_id = $2",
+}
+
+func (s *postgres) SetPublicStats(ctx context.Context, id string, public bool) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var url ShortURL
+	err := s.db.GetContext(ctx, &url, "update urls set public_stats = $1 where id = $2 returning "+urlColumns, public, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrNotFound
+		}
+		return ShortURL{}, fmt.Errorf("set url public stats: %w", err)
+	}
+
+	return url, nil
+}
+
+func (s *postgres) SetMetadata(ctx context.Context, id string, metadata LinkMetadata) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var url ShortURL
+	err := s.db.GetContext(ctx, &url, "update urls set metadata = $1 where id = $2 returning "+urlColumns, metadata, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrNotFound
+		}
+		return ShortURL{}, fmt.Errorf("set url metadata: %w", err)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const canonicalBackfillBatch = 1000
+
+// BackfillCanonicalURLs sets canonical URLs of links created before they were stored.
+// Links whose canonical URL is a copy of the raw one are recomputed too, earlier
+// migrations filled the column that way. Links whose canonical URL is taken by
+// another link keep none, they stay separate links.
+func BackfillCanonicalURLs(ctx context.Context, db *sqlx.DB, canonicalize func(rawURL string) (string, error)) (int, error) {
+	var updated int
+	lastID := 0
+	for {
+		var rows []struct {
+			ID           int            `db:"id"`
+			URL          string         `db:"url"`
+			CanonicalURL sql.NullString `db:"canonical_url"`
+		}
+		err := db.SelectContext(
+			ctx,
+			&rows,
+			`select id, url, canonical_url from urls
+where id > $1 and (canonical_url is null or canonical_url = url)
+order by id
+limit $2`,
+			lastID,
+			canonicalBackfillBatch,
+		)
+		if err != nil {
+			return updated, fmt.Errorf("find urls: %w", err)
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			canonicalURL, err := canonicalize(row.URL)
+			if err != nil || (row.CanonicalURL.Valid && canonicalURL == row.CanonicalURL.String) {
+				continue
+			}
+			res, err := db.ExecContext(
+				ctx,
+				"update urls set canonical_url = $2 where id = $1 and not exists (select 1 from urls where canonical_url = $2 and "+plainURLCondition+")",
+				row.ID,
+				canonicalURL,
+			)
+			if err != nil {
+				return updated, fmt.Errorf("update url %d: %w", row.ID, err)
+			}
+			if n, err := res.RowsAffected(); err == nil {
+				updated += int(n)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *postgres) ConsumeClick(ctx context.Context, id string) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var url ShortURL
+	err := s.db.GetContext(
+		ctx,
+		&url,
+		`update urls set clicks_left = clicks_left - 1
+where id = $1 and max_clicks > 0 and clicks_left > 0
+returning `+urlColumns,
+		id,
+	)
+	if err == nil {
+		return url, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ShortURL{}, fmt.Errorf("consume url click: %w", err)
+	}
+
+	url, err = s.GetByID(ctx, id)
+	if err != nil {
+		return ShortURL{}, err
+	}
+	if url.MaxClicks > 0 {
+		return url, ErrExhausted
+	}
+
+	return url, nil
+}
+
+func (s *postgres) RecordClick(ctx context.Context, click Click) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		"insert into clicks (url_id, variant, country, city, is_bot, created_at) values ($1, $2, $3, $4, $5, $6)",
+		click.URLID,
+		click.Variant,
+		click.Country,
+		click.City,
+		click.Bot,
+		click.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert click: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) CountClicks(ctx context.Context, urlID string) ([]ClickCount, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var counts []ClickCount
+	err := s.db.SelectContext(
+		ctx,
+		&counts,
+		`select variant, country, city, is_bot, count(*) as clicks
+from clicks
+where url_id = $1
+group by variant, country, city, is_bot
+order by variant, country, city, is_bot`,
+		urlID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("count clicks: %w", err)
+	}
+
+	return counts, nil
+}
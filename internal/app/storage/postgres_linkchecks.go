@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *postgres) FindCheckableURLs(ctx context.Context, now time.Time) ([]ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var urls []ShortURL
+	err := s.db.SelectContext(
+		ctx,
+		&urls,
+		`select `+urlColumns+` from urls
+where not is_deleted
+  and (not_before is null or not_before <= $1)
+  and (not_after is null or not_after > $1)
+  and max_clicks = 0
+  and password_hash = ''`,
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find active urls: %w", err)
+	}
+
+	return urls, nil
+}
+
+func (s *postgres) RecordLinkCheck(ctx context.Context, check LinkCheck) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var url ShortURL
+	err = tx.GetContext(
+		ctx,
+		&url,
+		`update urls set link_status = $2, link_checked_at = $3,
+    link_failures = case when $4 then 0 else link_failures + 1 end
+where id = $1
+returning `+urlColumns,
+		check.URLID,
+		check.Status,
+		check.CheckedAt,
+		check.OK(),
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrNotFound
+		}
+		return ShortURL{}, fmt.Errorf("update url link state: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"insert into link_checks (url_id, status, error, checked_at) values ($1, $2, $3, $4)",
+		check.URLID,
+		check.Status,
+		check.Error,
+		check.CheckedAt,
+	)
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("insert link check: %w", err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`delete from link_checks where url_id = $1 and id not in (
+    select id from link_checks where url_id = $1 order by checked_at desc limit $2
+)`,
+		check.URLID,
+		maxLinkChecks,
+	)
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("trim link checks: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ShortURL{}, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return url, nil
+}
+
+func (s *postgres) FindLinkChecks(ctx context.Context, urlID string) ([]LinkCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var checks []LinkCheck
+	err := s.db.SelectContext(
+		ctx,
+		&checks,
+		"select url_id, status, error, checked_at from link_checks where url_id = $1 order by checked_at desc",
+		urlID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find link checks: %w", err)
+	}
+
+	return checks, nil
+}
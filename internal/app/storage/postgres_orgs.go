@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *postgres) CreateOrg(ctx context.Context, org Org, ownerID string) (Org, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Org{}, fmt.Errorf("create tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, "insert into orgs (id, name) values ($1, $2)", org.ID, org.Name)
+	if err != nil {
+		return Org{}, fmt.Errorf("insert org to DB: %w", err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"insert into org_members (org_id, user_id, role) values ($1, $2, $3)",
+		org.ID,
+		ownerID,
+		RoleOwner,
+	)
+	if err != nil {
+		return Org{}, fmt.Errorf("insert org owner to DB: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Org{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	return org, nil
+}
+
+func (s *postgres) GetOrg(ctx context.Context, id string) (Org, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var org Org
+	err := s.db.GetContext(ctx, &org, "select id, name from orgs where id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Org{}, ErrNotFound
+		}
+		return Org{}, fmt.Errorf("get org: %w", err)
+	}
+
+	return org, nil
+}
+
+func (s *postgres) FindUserOrgs(ctx context.Context, userID string) []UserOrg {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var orgs []UserOrg
+	err := s.db.SelectContext(
+		ctx,
+		&orgs,
+		"select o.id, o.name, m.role from orgs o join org_members m on m.org_id = o.id where m.user_id = $1 order by o.name",
+		userID,
+	)
+	if err != nil {
+		return nil
+	}
+
+	return orgs
+}
+
+func (s *postgres) GetOrgMember(ctx context.Context, orgID, userID string) (OrgMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var member OrgMember
+	err := s.db.GetContext(
+		ctx,
+		&member,
+		"select org_id, user_id, role from org_members where org_id = $1 and user_id = $2",
+		orgID,
+		userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return OrgMember{}, ErrNotFound
+		}
+		return OrgMember{}, fmt.Errorf("get org member: %w", err)
+	}
+
+	return member, nil
+}
+
+func (s *postgres) FindOrgMembers(ctx context.Context, orgID string) []OrgMember {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var members []OrgMember
+	err := s.db.SelectContext(
+		ctx,
+		&members,
+		"select org_id, user_id, role from org_members where org_id = $1 order by user_id",
+		orgID,
+	)
+	if err != nil {
+		return nil
+	}
+
+	return members
+}
+
+func (s *postgres) SetOrgMember(ctx context.Context, member OrgMember) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.NamedExecContext(
+		ctx,
+		"insert into org_members (org_id, user_id, role) values (:org_id, :user_id, :role) on conflict (org_id, user_id) do update set role = excluded.role",
+		&member,
+	)
+	if err != nil {
+		return fmt.Errorf("set org member: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) RemoveOrgMember(ctx context.Context, orgID, userID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "delete from org_members where org_id = $1 and user_id = $2", orgID, userID)
+	if err != nil {
+		return fmt.Errorf("remove org member: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("remove org member: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *postgres) UpdateTarget(ctx context.Context, url ShortURL, editorID string) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("create tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var current ShortURL
+	err = tx.GetContext(ctx, &current, "select "+urlColumns+" from urls where id = $1 for update", url.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrNotFound
+		}
+		return ShortURL{}, fmt.Errorf("get url: %w", err)
+	}
+
+	if !current.HasOptions() {
+		var duplicate ShortURL
+		err = tx.GetContext(
+			ctx,
+			&duplicate,
+			"select "+urlColumns+" from urls where (url = $1 or canonical_url = nullif($2, '')) and id <> $3 and "+plainURLCondition+" limit 1",
+			url.LongURL,
+			url.CanonicalURL,
+			url.ID,
+		)
+		if err == nil {
+			return duplicate, ErrAlreadyExist
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, fmt.Errorf("get duplicated url: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"insert into url_revisions (url_id, url, editor_id) values ($1, $2, $3)",
+		current.ID,
+		current.LongURL,
+		editorID,
+	)
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("insert revision: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"update urls set url = $1, canonical_url = nullif($2, '') where id = $3",
+		url.LongURL,
+		url.CanonicalURL,
+		url.ID,
+	)
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("update url: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ShortURL{}, fmt.Errorf("commit tx: %w", err)
+	}
+
+	current.LongURL = url.LongURL
+	current.CanonicalURL = url.CanonicalURL
+
+	return current, nil
+}
+
+func (s *postgres) FindRevisions(ctx context.Context, urlID string) []Revision {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var revisions []Revision
+	err := s.db.SelectContext(
+		ctx,
+		&revisions,
+		"select id, url_id, url, editor_id, created_at from url_revisions where url_id = $1 order by id",
+		urlID,
+	)
+	if err != nil {
+		return nil
+	}
+
+	return revisions
+}
+
+func (s *postgres) GetRevision(ctx context.Context, urlID string, revisionID int) (Revision, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var rev Revision
+	err := s.db.GetContext(
+		ctx,
+		&rev,
+		"select id, url_id, url, editor_id, created_at from url_revisions where url_id = $1 and id = $2",
+		urlID,
+		revisionID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Revision{}, ErrNotFound
+		}
+		return Revision{}, fmt.Errorf("get revision: %w", err)
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s *postgres) FindClicks(ctx context.Context, urlID string, from, to time.Time) ([]Click, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var clicks []Click
+	err := s.db.SelectContext(
+		ctx,
+		&clicks,
+		`select url_id, variant, country, city, is_bot, created_at
+from clicks
+where url_id = $1 and created_at >= $2 and created_at < $3
+order by created_at`,
+		urlID,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find clicks: %w", err)
+	}
+
+	return clicks, nil
+}
+
+// RollupClicks runs without the query timeout, the first run rebuilds rollups from all clicks.
+func (s *postgres) RollupClicks(ctx context.Context, since time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("create tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, g := range []Granularity{GranularityHour, GranularityDay} {
+		_, err := tx.ExecContext(
+			ctx,
+			`insert into click_rollups (url_id, granularity, bucket, clicks, bot_clicks)
+select url_id,
+       cast($1 as text),
+       date_trunc(cast($1 as text), created_at at time zone 'UTC') at time zone 'UTC' as bucket,
+       count(*) filter (where not is_bot),
+       count(*) filter (where is_bot)
+from clicks
+where created_at >= $2
+group by url_id, bucket
+on conflict (url_id, granularity, bucket) do update
+    set clicks = excluded.clicks, bot_clicks = excluded.bot_clicks`,
+			string(g),
+			g.Truncate(since),
+		)
+		if err != nil {
+			return fmt.Errorf("rollup clicks by %s: %w", g, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) FindURLRollups(ctx context.Context, urlID string, granularity Granularity, from, to time.Time) ([]Rollup, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var rollups []Rollup
+	err := s.db.SelectContext(
+		ctx,
+		&rollups,
+		`select url_id, granularity, bucket, clicks, bot_clicks
+from click_rollups
+where url_id = $1 and granularity = $2 and bucket >= $3 and bucket < $4
+order by bucket`,
+		urlID,
+		string(granularity),
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find url rollups: %w", err)
+	}
+
+	return rollups, nil
+}
+
+func (s *postgres) FindUserRollups(ctx context.Context, userID string, granularity Granularity, from, to time.Time) ([]Rollup, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var rollups []Rollup
+	err := s.db.SelectContext(
+		ctx,
+		&rollups,
+		`select '' as url_id, r.granularity, r.bucket, sum(r.clicks) as clicks, sum(r.bot_clicks) as bot_clicks
+from click_rollups r
+         join urls u on u.id = r.url_id
+where u.user_id = $1 and r.granularity = $2 and r.bucket >= $3 and r.bucket < $4
+group by r.granularity, r.bucket
+order by r.bucket`,
+		userID,
+		string(granularity),
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find user rollups: %w", err)
+	}
+
+	return rollups, nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *postgres) SetRules(ctx context.Context, id string, rules RedirectRules) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var url ShortURL
+	err := s.db.GetContext(ctx, &url, "update urls set rules = $1 where id = $2 returning "+urlColumns, rules, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrNotFound
+		}
+		return ShortURL{}, fmt.Errorf("set url rules: %w", err)
+	}
+
+	return url, nil
+}
+
+func (s *postgres) SetVariants(ctx context.Context, id string, variants Variants) (ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var url ShortURL
+	err := s.db.GetContext(ctx, &url, "update urls set variants = $1 where id = $2 returning "+urlColumns, variants, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ShortURL{}, ErrNotFound
+		}
+		return ShortURL{}, fmt.Errorf("set url variants: %w", err)
+	}
+
+	return url, nil
+}
@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	folderColumns = "id, user_id, name, created_at"
+	tagColumns    = "id, user_id, name, created_at"
+)
+
+func (s *postgres) CreateFolder(ctx context.Context, folder Folder) (Folder, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.db.GetContext(
+		ctx,
+		&folder,
+		"insert into folders (id, user_id, name) values ($1, $2, $3) on conflict (user_id, name) do nothing returning "+folderColumns,
+		folder.ID,
+		folder.UserID,
+		folder.Name,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Folder{}, ErrNameTaken
+		}
+		return Folder{}, fmt.Errorf("create folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+func (s *postgres) GetFolder(ctx context.Context, id string) (Folder, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var folder Folder
+	err := s.db.GetContext(ctx, &folder, "select "+folderColumns+" from folders where id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Folder{}, ErrNotFound
+		}
+		return Folder{}, fmt.Errorf("get folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+func (s *postgres) FindUserFolders(ctx context.Context, userID string) ([]Folder, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var folders []Folder
+	err := s.db.SelectContext(ctx, &folders, "select "+folderColumns+" from folders where user_id = $1 order by name", userID)
+	if err != nil {
+		return nil, fmt.Errorf("find user folders: %w", err)
+	}
+
+	return folders, nil
+}
+
+func (s *postgres) RenameFolder(ctx context.Context, userID, id, name string) (Folder, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var folder Folder
+	err := s.db.GetContext(
+		ctx,
+		&folder,
+		`update folders set name = $3
+where id = $1 and user_id = $2 and not exists (select 1 from folders where user_id = $2 and name = $3 and id <> $1)
+returning `+folderColumns,
+		id,
+		userID,
+		name,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		if f, err := s.GetFolder(ctx, id); err == nil && f.UserID == userID {
+			return Folder{}, ErrNameTaken
+		}
+		return Folder{}, ErrNotFound
+	}
+	if err != nil {
+		return Folder{}, fmt.Errorf("rename folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+func (s *postgres) DeleteFolder(ctx context.Context, userID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "delete from folders where id = $1 and user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("delete folder: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *postgres) MoveURLs(ctx context.Context, ids []string, folderID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	if folderID != "" {
+		if _, err := s.GetFolder(ctx, folderID); err != nil {
+			return err
+		}
+	}
+
+	query, args, err := sqlx.In("update urls set folder_id = nullif(?, '')::uuid where id in (?)", folderID, ids)
+	if err != nil {
+		return fmt.Errorf("prepare query: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, s.db.Rebind(query), args...); err != nil {
+		return fmt.Errorf("move urls: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) CreateTag(ctx context.Context, tag Tag) (Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.db.GetContext(
+		ctx,
+		&tag,
+		"insert into tags (id, user_id, name) values ($1, $2, $3) on conflict (user_id, name) do nothing returning "+tagColumns,
+		tag.ID,
+		tag.UserID,
+		tag.Name,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tag{}, ErrNameTaken
+		}
+		return Tag{}, fmt.Errorf("create tag: %w", err)
+	}
+
+	return tag, nil
+}
+
+func (s *postgres) GetTag(ctx context.Context, id string) (Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var tag Tag
+	err := s.db.GetContext(ctx, &tag, "select "+tagColumns+" from tags where id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Tag{}, ErrNotFound
+		}
+		return Tag{}, fmt.Errorf("get tag: %w", err)
+	}
+
+	return tag, nil
+}
+
+func (s *postgres) FindUserTags(ctx context.Context, userID string) ([]Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var tags []Tag
+	err := s.db.SelectContext(ctx, &tags, "select "+tagColumns+" from tags where user_id = $1 order by name", userID)
+	if err != nil {
+		return nil, fmt.Errorf("find user tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (s *postgres) RenameTag(ctx context.Context, userID, id, name string) (Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var tag Tag
+	err := s.db.GetContext(
+		ctx,
+		&tag,
+		`update tags set name = $3
+where id = $1 and user_id = $2 and not exists (select 1 from tags where user_id = $2 and name = $3 and id <> $1)
+returning `+tagColumns,
+		id,
+		userID,
+		name,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		if t, err := s.GetTag(ctx, id); err == nil && t.UserID == userID {
+			return Tag{}, ErrNameTaken
+		}
+		return Tag{}, ErrNotFound
+	}
+	if err != nil {
+		return Tag{}, fmt.Errorf("rename tag: %w", err)
+	}
+
+	return tag, nil
+}
+
+func (s *postgres) DeleteTag(ctx context.Context, userID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "delete from tags where id = $1 and user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("delete tag: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *postgres) TagURLs(ctx context.Context, ids, add, remove []string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("create tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if len(add) > 0 {
+		var found int
+		query, args, err := sqlx.In("select count(*) from tags where id in (?)", add)
+		if err != nil {
+			return fmt.Errorf("prepare query: %w", err)
+		}
+		if err := tx.GetContext(ctx, &found, tx.Rebind(query), args...); err != nil {
+			return fmt.Errorf("find tags: %w", err)
+		}
+		if found != len(add) {
+			return ErrNotFound
+		}
+
+		query, args, err = sqlx.In(
+			"insert into url_tags (url_id, tag_id) select u.id, t.id from urls u, tags t where u.id in (?) and t.id in (?) on conflict do nothing",
+			ids,
+			add,
+		)
+		if err != nil {
+			return fmt.Errorf("prepare query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+			return fmt.Errorf("add url tags: %w", err)
+		}
+	}
+	if len(remove) > 0 {
+		query, args, err := sqlx.In("delete from url_tags where url_id in (?) and tag_id in (?)", ids, remove)
+		if err != nil {
+			return fmt.Errorf("prepare query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+			return fmt.Errorf("remove url tags: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) FindURLTags(ctx context.Context, userID string, ids []string) (map[string][]Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	found := make(map[string][]Tag)
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	query, args, err := sqlx.In(
+		`select cast(ut.url_id as text) as url_id, t.id, t.user_id, t.name, t.created_at
+from url_tags ut join tags t on t.id = ut.tag_id
+where ut.url_id in (?) and t.user_id = ?
+order by t.name`,
+		ids,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("prepare query: %w", err)
+	}
+
+	var rows []struct {
+		URLID string `db:"url_id"`
+		Tag
+	}
+	if err := s.db.SelectContext(ctx, &rows, s.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("find url tags: %w", err)
+	}
+	for _, row := range rows {
+		found[row.URLID] = append(found[row.URLID], row.Tag)
+	}
+
+	return found, nil
+}
+
+func (s *postgres) FindURLs(ctx context.Context, filter URLFilter) ([]ShortURL, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := "select " + urlColumns + " from urls where user_id = ?"
+	args := []interface{}{filter.UserID}
+	if filter.OrgID != "" {
+		query = "select " + urlColumns + " from urls where org_id = ?"
+		args = []interface{}{filter.OrgID}
+	}
+	if filter.FolderID != "" {
+		// ids are uuids, others can not match and would fail the query
+		if _, err := uuid.Parse(filter.FolderID); err != nil {
+			return nil, nil
+		}
+		query += " and folder_id = ?"
+		args = append(args, filter.FolderID)
+	}
+	if len(filter.TagIDs) > 0 {
+		tagIDs := make(map[string]struct{})
+		for _, id := range filter.TagIDs {
+			if _, err := uuid.Parse(id); err != nil {
+				return nil, nil
+			}
+			tagIDs[id] = struct{}{}
+		}
+		query += ` and id in (select ut.url_id
+from url_tags ut join tags t on t.id = ut.tag_id
+where ut.tag_id in (?) and t.user_id = ?
+group by ut.url_id
+having count(*) = ?)`
+		args = append(args, filter.TagIDs, filter.UserID, len(tagIDs))
+	}
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("prepare query: %w", err)
+	}
+
+	var urls []ShortURL
+	if err := s.db.SelectContext(ctx, &urls, s.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("find urls: %w", err)
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *postgres) SaveUTMTemplate(ctx context.Context, tmpl UTMTemplate) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.NamedExecContext(
+		ctx,
+		`insert into utm_templates (user_id, name, source, medium, campaign, term, content)
+values (:user_id, :name, :source, :medium, :campaign, :term, :content)
+on conflict (user_id, name) do update set
+    source   = excluded.source,
+    medium   = excluded.medium,
+    campaign = excluded.campaign,
+    term     = excluded.term,
+    content  = excluded.content`,
+		&tmpl,
+	)
+	if err != nil {
+		return fmt.Errorf("save utm template: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) GetUTMTemplate(ctx context.Context, userID, name string) (UTMTemplate, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var tmpl UTMTemplate
+	err := s.db.GetContext(
+		ctx,
+		&tmpl,
+		"select user_id, name, source, medium, campaign, term, content from utm_templates where user_id = $1 and name = $2",
+		userID,
+		name,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return UTMTemplate{}, ErrNotFound
+		}
+		return UTMTemplate{}, fmt.Errorf("get utm template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+func (s *postgres) FindUTMTemplates(ctx context.Context, userID string) []UTMTemplate {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var templates []UTMTemplate
+	err := s.db.SelectContext(
+		ctx,
+		&templates,
+		"select user_id, name, source, medium, campaign, term, content from utm_templates where user_id = $1 order by name",
+		userID,
+	)
+	if err != nil {
+		return nil
+	}
+
+	return templates
+}
+
+func (s *postgres) DeleteUTMTemplate(ctx context.Context, userID, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "delete from utm_templates where user_id = $1 and name = $2", userID, name)
+	if err != nil {
+		return fmt.Errorf("delete utm template: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete utm template: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	webhookColumns  = "id, user_id, url, secret, events, created_at"
+	deliveryColumns = "id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status, last_error, created_at, delivered_at"
+)
+
+func (s *postgres) CreateWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.db.GetContext(
+		ctx,
+		&hook,
+		"insert into webhooks (id, user_id, url, secret, events) values ($1, $2, $3, $4, $5) returning "+webhookColumns,
+		hook.ID,
+		hook.UserID,
+		hook.URL,
+		hook.Secret,
+		hook.Events,
+	)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("create webhook: %w", err)
+	}
+
+	return hook, nil
+}
+
+func (s *postgres) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hook Webhook
+	err := s.db.GetContext(ctx, &hook, "select "+webhookColumns+" from webhooks where id = $1", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, ErrNotFound
+		}
+		return Webhook{}, fmt.Errorf("get webhook: %w", err)
+	}
+
+	return hook, nil
+}
+
+func (s *postgres) FindUserWebhooks(ctx context.Context, userID string) ([]Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hooks []Webhook
+	err := s.db.SelectContext(ctx, &hooks, "select "+webhookColumns+" from webhooks where user_id = $1 order by created_at", userID)
+	if err != nil {
+		return nil, fmt.Errorf("find user webhooks: %w", err)
+	}
+
+	return hooks, nil
+}
+
+func (s *postgres) DeleteWebhook(ctx context.Context, userID, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "delete from webhooks where id = $1 and user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *postgres) SaveDelivery(ctx context.Context, d Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.NamedExecContext(
+		ctx,
+		`insert into webhook_deliveries (`+deliveryColumns+`)
+values (:id, :webhook_id, :event, :payload, :status, :attempts, :next_attempt_at, :last_status, :last_error, :created_at, :delivered_at)
+on conflict (id) do update set
+    status          = excluded.status,
+    attempts        = excluded.attempts,
+    next_attempt_at = excluded.next_attempt_at,
+    last_status     = excluded.last_status,
+    last_error      = excluded.last_error,
+    delivered_at    = excluded.delivered_at`,
+		&d,
+	)
+	if err != nil {
+		return fmt.Errorf("save webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (s *postgres) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var due []Delivery
+	err := s.db.SelectContext(
+		ctx,
+		&due,
+		`update webhook_deliveries set next_attempt_at = $4
+where id in (select id
+             from webhook_deliveries
+             where status = $1 and next_attempt_at <= $2
+             order by next_attempt_at
+             limit $3 for update skip locked)
+returning `+deliveryColumns,
+		DeliveryPending,
+		now,
+		limit,
+		now.Add(lease),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim due webhook deliveries: %w", err)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+
+	return due, nil
+}
+
+func (s *postgres) PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`delete from webhook_deliveries
+where (status = $1 and delivered_at < $3) or (status = $2 and created_at < $3)`,
+		DeliveryDelivered,
+		DeliveryDead,
+		finishedBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("purge webhook deliveries: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purge webhook deliveries: %w", err)
+	}
+
+	return int(n), nil
+}
+
+func (s *postgres) FindDeliveries(ctx context.Context, webhookID, status string) ([]Delivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var found []Delivery
+	err := s.db.SelectContext(
+		ctx,
+		&found,
+		"select "+deliveryColumns+" from webhook_deliveries where webhook_id = $1 and ($2 = '' or status = $2) order by created_at desc",
+		webhookID,
+		status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("find webhook deliveries: %w", err)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunRetention permanently removes links deleted more than retention ago,
+// checking every interval until ctx is done.
+func RunRetention(ctx context.Context, s URLStorage, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDeleted(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("purge deleted urls: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted urls", purged)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
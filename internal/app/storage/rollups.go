@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunRollups keeps click rollups up to date, recomputing every interval until ctx is done.
+// The first run rebuilds all rollups, later ones only the buckets of the current day,
+// which also covers clicks recorded late.
+func RunRollups(ctx context.Context, s ClickStorage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var since time.Time
+	for {
+		started := time.Now()
+		if err := s.RollupClicks(ctx, since); err != nil {
+			log.Printf("rollup clicks: %v", err)
+		} else {
+			since = GranularityDay.Truncate(started)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
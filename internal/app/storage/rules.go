@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// RedirectRule sends clicks matching all its non-empty conditions to URL instead of
+// the link target. Rules of a link are evaluated in order, the first match wins.
+type RedirectRule struct {
+	Platform string
+	Language string
+	Country  string
+	// TimeFrom and TimeTo bound UTC time of day as "15:04", the range may wrap midnight.
+	TimeFrom string
+	TimeTo   string
+	URL      string
+}
+
+type RedirectRules []RedirectRule
+
+func (r RedirectRules) Value() (driver.Value, error) {
+	return jsonValue(r, r == nil)
+}
+
+func (r *RedirectRules) Scan(src interface{}) error {
+	var rules RedirectRules
+	if err := scanJSON(src, &rules); err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	*r = rules
+
+	return nil
+}
+
+// jsonValue stores lists in jsonb columns, which are not nullable.
+func jsonValue(v interface{}, empty bool) (driver.Value, error) {
+	if empty {
+		return "[]", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func scanJSON(src interface{}, dst interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	}
+
+	return errors.New("unsupported json column type")
+}
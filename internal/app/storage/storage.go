@@ -3,17 +3,146 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
 	ErrNotFound     = errors.New("not found")
 	ErrAlreadyExist = errors.New("url already exist")
+	ErrLoginTaken   = errors.New("login already taken")
+	ErrExhausted    = errors.New("url clicks exhausted")
+	ErrNameTaken    = errors.New("name already taken")
+	ErrTotalQuota   = errors.New("total links quota exceeded")
+	ErrDailyQuota   = errors.New("daily links quota exceeded")
 )
 
+type Storage interface {
+	URLStorage
+	UserStorage
+	OrgStorage
+	UTMTemplateStorage
+	ClickStorage
+	LinkCheckStorage
+	WebhookStorage
+	FolderStorage
+	TagStorage
+}
+
 type URLStorage interface {
 	Create(context.Context, ShortURL) (ShortURL, error)
 	GetByID(context.Context, string) (ShortURL, error)
 	FindByUserID(context.Context, string) []ShortURL
-	CreateBatch(context.Context, []ShortURL) ([]ShortURL, error)
+	// CreateBatch creates links of one user and returns them with duplicates found instead,
+	// only created ones are marked Inserted. Duplicates do not count against quota, when new
+	// links exceed it ErrTotalQuota or ErrDailyQuota is returned and none are created.
+	CreateBatch(ctx context.Context, urls []ShortURL, quota Quota) ([]ShortURL, error)
 	DeleteBatch(context.Context, string, []string) error
+	// RestoreBatch undeletes links deleted after deletedAfter and returns restored ones.
+	RestoreBatch(ctx context.Context, userID string, ids []string, deletedAfter time.Time) ([]ShortURL, error)
+	// PurgeDeleted permanently removes links deleted before deletedBefore.
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error)
+	FindByOrgID(context.Context, string) []ShortURL
+	CountUserURLs(ctx context.Context, userID string, since time.Time) (URLCounts, error)
+	UpdateTarget(ctx context.Context, url ShortURL, editorID string) (ShortURL, error)
+	FindRevisions(ctx context.Context, urlID string) []Revision
+	GetRevision(ctx context.Context, urlID string, revisionID int) (Revision, error)
+	// ConsumeClick decrements clicks left of a link limited by MaxClicks,
+	// ErrExhausted is returned when none left.
+	ConsumeClick(ctx context.Context, id string) (ShortURL, error)
+	SetRules(ctx context.Context, id string, rules RedirectRules) (ShortURL, error)
+	SetVariants(ctx context.Context, id string, variants Variants) (ShortURL, error)
+	SetPublicStats(ctx context.Context, id string, public bool) (ShortURL, error)
+	SetMetadata(ctx context.Context, id string, metadata LinkMetadata) (ShortURL, error)
+}
+
+type ClickStorage interface {
+	RecordClick(context.Context, Click) error
+	// CountClicks returns clicks of a link grouped by variant, country, city and bot flag.
+	CountClicks(ctx context.Context, urlID string) ([]ClickCount, error)
+	// FindClicks returns raw clicks of a link created within [from, to).
+	FindClicks(ctx context.Context, urlID string, from, to time.Time) ([]Click, error)
+	// RollupClicks recomputes rollups of all buckets starting at or after since.
+	RollupClicks(ctx context.Context, since time.Time) error
+	FindURLRollups(ctx context.Context, urlID string, granularity Granularity, from, to time.Time) ([]Rollup, error)
+	// FindUserRollups sums rollups of links currently owned by userID.
+	FindUserRollups(ctx context.Context, userID string, granularity Granularity, from, to time.Time) ([]Rollup, error)
+}
+
+type LinkCheckStorage interface {
+	// FindCheckableURLs returns links served at now whose destinations may be checked,
+	// skipping deleted, scheduled, expired, click limited and password protected ones.
+	FindCheckableURLs(ctx context.Context, now time.Time) ([]ShortURL, error)
+	// RecordLinkCheck saves check to link history and updates its last check state.
+	RecordLinkCheck(context.Context, LinkCheck) (ShortURL, error)
+	// FindLinkChecks returns status history of a link, newest first.
+	FindLinkChecks(ctx context.Context, urlID string) ([]LinkCheck, error)
+}
+
+type WebhookStorage interface {
+	CreateWebhook(context.Context, Webhook) (Webhook, error)
+	GetWebhook(context.Context, string) (Webhook, error)
+	FindUserWebhooks(ctx context.Context, userID string) ([]Webhook, error)
+	// DeleteWebhook removes a webhook of userID together with its deliveries.
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	// SaveDelivery creates or updates a delivery.
+	SaveDelivery(context.Context, Delivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now, oldest first,
+	// postponing them by lease so concurrent dispatchers skip them while they are attempted.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// PurgeDeliveries permanently removes deliveries delivered before finishedBefore
+	// and dead ones created before it.
+	PurgeDeliveries(ctx context.Context, finishedBefore time.Time) (int, error)
+	// FindDeliveries returns deliveries of a webhook with status, any if empty, newest first.
+	FindDeliveries(ctx context.Context, webhookID, status string) ([]Delivery, error)
+}
+
+type FolderStorage interface {
+	CreateFolder(context.Context, Folder) (Folder, error)
+	GetFolder(context.Context, string) (Folder, error)
+	FindUserFolders(ctx context.Context, userID string) ([]Folder, error)
+	RenameFolder(ctx context.Context, userID, id, name string) (Folder, error)
+	// DeleteFolder removes a folder of userID, its links are left without folder.
+	DeleteFolder(ctx context.Context, userID, id string) error
+	// MoveURLs puts links with ids into folderID, empty folderID takes them out of folders.
+	MoveURLs(ctx context.Context, ids []string, folderID string) error
+}
+
+type TagStorage interface {
+	CreateTag(context.Context, Tag) (Tag, error)
+	GetTag(context.Context, string) (Tag, error)
+	FindUserTags(ctx context.Context, userID string) ([]Tag, error)
+	RenameTag(ctx context.Context, userID, id, name string) (Tag, error)
+	// DeleteTag removes a tag of userID from all links and deletes it.
+	DeleteTag(ctx context.Context, userID, id string) error
+	// TagURLs adds tags add to links with ids and removes tags remove from them.
+	TagURLs(ctx context.Context, ids, add, remove []string) error
+	// FindURLTags returns tags of userID set on links with ids, keyed by link id.
+	FindURLTags(ctx context.Context, userID string, ids []string) (map[string][]Tag, error)
+	FindURLs(ctx context.Context, filter URLFilter) ([]ShortURL, error)
+}
+
+type UserStorage interface {
+	CreateUser(context.Context, User) (User, error)
+	GetUserByID(context.Context, string) (User, error)
+	GetUserByLogin(context.Context, string) (User, error)
+	// MergeUserURLs moves links, folders, tags, UTM templates and webhooks of fromUserID
+	// to toUserID, folders, tags and templates named like ones of toUserID merge into those.
+	MergeUserURLs(ctx context.Context, fromUserID, toUserID string) error
+}
+
+type OrgStorage interface {
+	CreateOrg(ctx context.Context, org Org, ownerID string) (Org, error)
+	GetOrg(context.Context, string) (Org, error)
+	FindUserOrgs(context.Context, string) []UserOrg
+	GetOrgMember(ctx context.Context, orgID, userID string) (OrgMember, error)
+	FindOrgMembers(context.Context, string) []OrgMember
+	SetOrgMember(context.Context, OrgMember) error
+	RemoveOrgMember(ctx context.Context, orgID, userID string) error
+}
+
+type UTMTemplateStorage interface {
+	SaveUTMTemplate(context.Context, UTMTemplate) error
+	GetUTMTemplate(ctx context.Context, userID, name string) (UTMTemplate, error)
+	FindUTMTemplates(ctx context.Context, userID string) []UTMTemplate
+	DeleteUTMTemplate(ctx context.Context, userID, name string) error
 }
@@ -0,0 +1,29 @@
+package storage
+
+import "database/sql/driver"
+
+// Variant is one of weighted destinations of a link split between visitors.
+type Variant struct {
+	Name   string
+	URL    string
+	Weight int
+}
+
+type Variants []Variant
+
+func (v Variants) Value() (driver.Value, error) {
+	return jsonValue(v, v == nil)
+}
+
+func (v *Variants) Scan(src interface{}) error {
+	var variants Variants
+	if err := scanJSON(src, &variants); err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		variants = nil
+	}
+	*v = variants
+
+	return nil
+}
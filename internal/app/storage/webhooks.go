@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead marks deliveries which ran out of attempts.
+	DeliveryDead = "dead"
+)
+
+// Webhook is an endpoint of a user notified about events of their links.
+type Webhook struct {
+	ID        string        `db:"id"`
+	UserID    string        `db:"user_id"`
+	URL       string        `db:"url"`
+	Secret    string        `db:"secret"`
+	Events    WebhookEvents `db:"events"`
+	CreatedAt time.Time     `db:"created_at"`
+}
+
+// Subscribed reports whether the webhook receives event, all events if none listed.
+func (w Webhook) Subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+type WebhookEvents []string
+
+func (e WebhookEvents) Value() (driver.Value, error) {
+	return jsonValue(e, e == nil)
+}
+
+func (e *WebhookEvents) Scan(src interface{}) error {
+	var events WebhookEvents
+	if err := scanJSON(src, &events); err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		events = nil
+	}
+	*e = events
+
+	return nil
+}
+
+// Delivery is an event queued for a webhook, pending ones are retried until
+// delivered or dead.
+type Delivery struct {
+	ID            string     `db:"id"`
+	WebhookID     string     `db:"webhook_id"`
+	Event         string     `db:"event"`
+	Payload       string     `db:"payload"`
+	Status        string     `db:"status"`
+	Attempts      int        `db:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	LastStatus    int        `db:"last_status"`
+	LastError     string     `db:"last_error"`
+	CreatedAt     time.Time  `db:"created_at"`
+	DeliveredAt   *time.Time `db:"delivered_at"`
+}
@@ -0,0 +1,27 @@
+package urlnorm
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// toASCII converts an internationalized host into its "xn--" form,
+// ASCII hosts such as IP literals are returned as is.
+func toASCII(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+
+	return idna.Lookup.ToASCII(host)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
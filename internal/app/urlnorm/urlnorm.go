@@ -0,0 +1,111 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+type Options struct {
+	// StripTrackingParams removes utm_* and click identifiers added by ad networks.
+	StripTrackingParams bool
+	// StripTrailingSlash treats "/page/" and "/page" as the same resource.
+	StripTrailingSlash bool
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+var trackingParams = map[string]struct{}{
+	"fbclid":  {},
+	"gclid":   {},
+	"dclid":   {},
+	"msclkid": {},
+	"yclid":   {},
+	"mc_cid":  {},
+	"mc_eid":  {},
+	"_ga":     {},
+	"igshid":  {},
+}
+
+// Canonicalize returns the canonical form of u used to detect duplicate links:
+// lower case scheme and host, punycode host, no default port, clean path and sorted query.
+func Canonicalize(u *url.URL, opts Options) (string, error) {
+	c := *u
+	c.Scheme = strings.ToLower(c.Scheme)
+
+	if c.Opaque != "" {
+		return c.String(), nil
+	}
+
+	host, err := toASCII(strings.TrimSuffix(strings.ToLower(c.Hostname()), "."))
+	if err != nil {
+		return "", fmt.Errorf("canonical host: %w", err)
+	}
+	if port := c.Port(); port != "" && port != defaultPorts[c.Scheme] {
+		host = host + ":" + port
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	c.Host = host
+
+	c.Path = cleanPath(c.Path, opts.StripTrailingSlash)
+	c.RawPath = ""
+	c.RawQuery = canonicalQuery(c.Query(), opts.StripTrackingParams)
+	c.ForceQuery = false
+
+	return c.String(), nil
+}
+
+func cleanPath(p string, stripTrailingSlash bool) string {
+	if p == "" || p == "/" {
+		return "/"
+	}
+
+	trailingSlash := strings.HasSuffix(p, "/")
+	p = path.Clean("/" + p)
+	if trailingSlash && !stripTrailingSlash && p != "/" {
+		p += "/"
+	}
+
+	return p
+}
+
+func canonicalQuery(query url.Values, stripTracking bool) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if stripTracking && isTrackingParam(key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range query[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(value))
+		}
+	}
+
+	return b.String()
+}
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "utm_") {
+		return true
+	}
+	_, ok := trackingParams[key]
+
+	return ok
+}
@@ -0,0 +1,97 @@
+package urlnorm
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		opts Options
+		want string
+	}{
+		{
+			name: "case folding and root path",
+			url:  "HTTP://Example.COM",
+			want: "http://example.com/",
+		},
+		{
+			name: "default port removal",
+			url:  "https://example.com:443/a",
+			want: "https://example.com/a",
+		},
+		{
+			name: "custom port kept",
+			url:  "https://example.com:8443/a",
+			want: "https://example.com:8443/a",
+		},
+		{
+			name: "dot segments",
+			url:  "http://example.com/a/./b/../c",
+			want: "http://example.com/a/c",
+		},
+		{
+			name: "trailing slash kept by default",
+			url:  "http://example.com/page/",
+			want: "http://example.com/page/",
+		},
+		{
+			name: "trailing slash stripped",
+			url:  "http://example.com/page/",
+			opts: Options{StripTrailingSlash: true},
+			want: "http://example.com/page",
+		},
+		{
+			name: "query ordering",
+			url:  "http://example.com/?b=2&a=1&a=0",
+			want: "http://example.com/?a=1&a=0&b=2",
+		},
+		{
+			name: "tracking params kept by default",
+			url:  "http://example.com/?utm_source=x&id=1",
+			want: "http://example.com/?id=1&utm_source=x",
+		},
+		{
+			name: "tracking params stripped",
+			url:  "http://example.com/?utm_source=x&fbclid=y&id=1",
+			opts: Options{StripTrackingParams: true},
+			want: "http://example.com/?id=1",
+		},
+		{
+			name: "empty query",
+			url:  "http://example.com/?",
+			want: "http://example.com/",
+		},
+		{
+			name: "idn host",
+			url:  "https://Bücher.example/",
+			want: "https://xn--bcher-kva.example/",
+		},
+		{
+			name: "cyrillic host",
+			url:  "http://пример.испытание/",
+			want: "http://xn--e1afmkfd.xn--80akhbyknj4f/",
+		},
+		{
+			name: "ipv6 host",
+			url:  "http://[::1]:80/",
+			want: "http://[::1]/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+
+			got, err := Canonicalize(u, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
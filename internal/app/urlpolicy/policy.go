@@ -0,0 +1,195 @@
+package urlpolicy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/virp/go-shortener/internal/app/safehttp"
+)
+
+const (
+	CodeSchemeNotAllowed = "scheme_not_allowed"
+	CodeMissingHost      = "missing_host"
+	CodeDomainNotAllowed = "domain_not_allowed"
+	CodeDomainDenied     = "domain_denied"
+	CodeBlocklisted      = "blocklisted"
+	CodePrivateAddress   = "private_address"
+	CodeSelfReference    = "self_reference"
+)
+
+// Violation describes why a target URL was rejected.
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+type Policy struct {
+	// AllowedSchemes lists accepted URL schemes, empty means http and https.
+	AllowedSchemes []string
+	// AllowedDomains restricts targets to the listed domains and their subdomains when not empty.
+	AllowedDomains []string
+	DeniedDomains  []string
+	// Blocklist holds known bad hosts, see LoadBlocklist.
+	Blocklist map[string]struct{}
+	// SelfHosts are hostnames the shortener is served from, links to them would loop.
+	SelfHosts       []string
+	AllowPrivateIPs bool
+	// Resolver is used to reject hostnames resolving to private addresses, nil disables lookups.
+	Resolver Resolver
+}
+
+func (p *Policy) Check(ctx context.Context, u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemeAllowed(scheme) {
+		return &Violation{Code: CodeSchemeNotAllowed, Message: fmt.Sprintf("scheme %q is not allowed", u.Scheme)}
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return &Violation{Code: CodeMissingHost, Message: "url must contain a host"}
+	}
+
+	for _, self := range p.SelfHosts {
+		if strings.EqualFold(self, host) {
+			return &Violation{Code: CodeSelfReference, Message: "url must not point to the shortener itself"}
+		}
+	}
+
+	if len(p.AllowedDomains) > 0 && !matchDomains(host, p.AllowedDomains) {
+		return &Violation{Code: CodeDomainNotAllowed, Message: fmt.Sprintf("domain %q is not allowed", host)}
+	}
+	if matchDomains(host, p.DeniedDomains) {
+		return &Violation{Code: CodeDomainDenied, Message: fmt.Sprintf("domain %q is denied", host)}
+	}
+	if p.blocklisted(host) {
+		return &Violation{Code: CodeBlocklisted, Message: fmt.Sprintf("host %q is blocklisted", host)}
+	}
+
+	if !p.AllowPrivateIPs {
+		if err := p.checkPrivate(ctx, host); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Policy) schemeAllowed(scheme string) bool {
+	schemes := p.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, s := range schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Policy) blocklisted(host string) bool {
+	for h := host; h != ""; {
+		if _, ok := p.Blocklist[h]; ok {
+			return true
+		}
+		i := strings.IndexByte(h, '.')
+		if i < 0 {
+			break
+		}
+		h = h[i+1:]
+	}
+
+	return false
+}
+
+func (p *Policy) checkPrivate(ctx context.Context, host string) error {
+	violation := &Violation{Code: CodePrivateAddress, Message: fmt.Sprintf("host %q points to a private address", host)}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return violation
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !safehttp.IsPublicIP(ip) {
+			return violation
+		}
+		return nil
+	}
+	if p.Resolver == nil {
+		return nil
+	}
+
+	addrs, err := p.Resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		// Unresolvable hosts are not private, the link may start working later.
+		return nil
+	}
+	for _, addr := range addrs {
+		if !safehttp.IsPublicIP(addr.IP) {
+			return violation
+		}
+	}
+
+	return nil
+}
+
+func matchDomains(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d == "" {
+			continue
+		}
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadBlocklist reads hosts from filename, one per line. Empty lines and
+// comments starting with # are skipped, hosts file format "0.0.0.0 host" is accepted too.
+func LoadBlocklist(filename string) (map[string]struct{}, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open blocklist: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	hosts := make(map[string]struct{})
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+		for _, host := range fields {
+			hosts[strings.TrimSuffix(strings.ToLower(host), ".")] = struct{}{}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read blocklist: %w", err)
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,99 @@
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticResolver map[string][]net.IPAddr
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return addrs, nil
+}
+
+func TestPolicy_Check(t *testing.T) {
+	p := &Policy{
+		DeniedDomains: []string{"denied.com"},
+		Blocklist:     map[string]struct{}{"malware.test": {}},
+		SelfHosts:     []string{"short.ly"},
+		Resolver: staticResolver{
+			"intranet.example.com": {{IP: net.ParseIP("10.0.0.1")}},
+			"public.example.com":   {{IP: net.ParseIP("93.184.216.34")}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode string
+	}{
+		{name: "public url", url: "https://public.example.com/path"},
+		{name: "unresolvable host", url: "https://unknown.example.com/path"},
+		{name: "javascript scheme", url: "javascript:alert(1)", wantCode: CodeSchemeNotAllowed},
+		{name: "file scheme", url: "file:///etc/passwd", wantCode: CodeSchemeNotAllowed},
+		{name: "localhost", url: "http://localhost:8080/", wantCode: CodePrivateAddress},
+		{name: "loopback ip", url: "http://127.0.0.1/", wantCode: CodePrivateAddress},
+		{name: "private ipv6", url: "http://[fd00::1]/", wantCode: CodePrivateAddress},
+		{name: "carrier-grade nat ip", url: "http://100.64.0.1/", wantCode: CodePrivateAddress},
+		{name: "resolves to private ip", url: "http://intranet.example.com/", wantCode: CodePrivateAddress},
+		{name: "self reference", url: "https://SHORT.LY/42", wantCode: CodeSelfReference},
+		{name: "denied subdomain", url: "https://www.denied.com/", wantCode: CodeDomainDenied},
+		{name: "blocklisted subdomain", url: "https://cdn.malware.test/x", wantCode: CodeBlocklisted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.ParseRequestURI(tt.url)
+			require.NoError(t, err)
+
+			err = p.Check(context.Background(), u)
+			if tt.wantCode == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var v *Violation
+			require.ErrorAs(t, err, &v)
+			assert.Equal(t, tt.wantCode, v.Code)
+		})
+	}
+}
+
+func TestPolicy_CheckAllowedDomains(t *testing.T) {
+	p := &Policy{AllowedDomains: []string{"example.com"}}
+
+	u, _ := url.Parse("https://docs.example.com/")
+	assert.NoError(t, p.Check(context.Background(), u))
+
+	u, _ = url.Parse("https://example.org/")
+	var v *Violation
+	require.ErrorAs(t, p.Check(context.Background(), u), &v)
+	assert.Equal(t, CodeDomainNotAllowed, v.Code)
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "blocklist")
+	require.NoError(t, err)
+	_, err = f.WriteString("# known bad hosts\nmalware.test\n0.0.0.0 phishing.test tracker.test # hosts format\n\nBAD.Example.\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	hosts, err := LoadBlocklist(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{
+		"malware.test":  {},
+		"phishing.test": {},
+		"tracker.test":  {},
+		"bad.example":   {},
+	}, hosts)
+}
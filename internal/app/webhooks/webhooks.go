@@ -0,0 +1,297 @@
+// Package webhooks queues link events for user webhooks and delivers them with retries.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+const (
+	EventLinkCreated = "link.created"
+	EventLinkDeleted = "link.deleted"
+	EventLinkClicked = "link.clicked"
+	// EventLinkBroken and EventLinkRecovered are emitted by the link checker.
+	EventLinkBroken    = "link.broken"
+	EventLinkRecovered = "link.recovered"
+
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	maxErrorLength = 200
+	purgeInterval  = time.Hour
+	eventQueueSize = 1024
+)
+
+// Events lists events webhooks may subscribe to.
+var Events = []string{EventLinkCreated, EventLinkDeleted, EventLinkClicked, EventLinkBroken, EventLinkRecovered}
+
+type payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type queuedEvent struct {
+	userID string
+	event  string
+	data   interface{}
+}
+
+type Dispatcher struct {
+	storage storage.WebhookStorage
+	client  *http.Client
+	events  chan queuedEvent
+	// MaxAttempts after which a delivery is moved to dead letters,
+	// attempts are Backoff, 2*Backoff, 4*Backoff and so on apart up to MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// BatchSize limits deliveries claimed per poll, Concurrency of them are sent at once.
+	// Claimed deliveries are skipped by other dispatchers for Lease, which should
+	// outlast sending the batch.
+	BatchSize   int
+	Concurrency int
+	Lease       time.Duration
+	// Retention after which delivered and dead deliveries are purged, 0 keeps them.
+	Retention time.Duration
+}
+
+func NewDispatcher(s storage.WebhookStorage, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		storage:     s,
+		client:      client,
+		events:      make(chan queuedEvent, eventQueueSize),
+		MaxAttempts: 8,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		BatchSize:   100,
+		Concurrency: 8,
+		Lease:       5 * time.Minute,
+		Retention:   7 * 24 * time.Hour,
+	}
+}
+
+// Emit queues event with data for webhooks of userID subscribed to it.
+func (d *Dispatcher) Emit(ctx context.Context, userID, event string, data interface{}) error {
+	if userID == "" {
+		return nil
+	}
+	hooks, err := d.storage.FindUserWebhooks(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, hook := range hooks {
+		if !hook.Subscribed(event) {
+			continue
+		}
+		id := uuid.NewString()
+		body, err := json.Marshal(payload{ID: id, Event: event, CreatedAt: now, Data: data})
+		if err != nil {
+			return err
+		}
+		err = d.storage.SaveDelivery(ctx, storage.Delivery{
+			ID:            id,
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       string(body),
+			Status:        storage.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Enqueue schedules Emit of event to be run by Run so hot paths never wait for storage,
+// false is returned when the queue is full and the event is dropped.
+func (d *Dispatcher) Enqueue(userID, event string, data interface{}) bool {
+	if userID == "" {
+		return true
+	}
+	select {
+	case d.events <- queuedEvent{userID: userID, event: event, data: data}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run emits queued events and sends due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	go d.emitQueued(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var purgedAt time.Time
+	for {
+		if err := d.DeliverDue(ctx); err != nil {
+			log.Printf("deliver webhooks: %v", err)
+		}
+		if d.Retention > 0 && time.Since(purgedAt) >= purgeInterval {
+			purgedAt = time.Now()
+			if _, err := d.storage.PurgeDeliveries(ctx, purgedAt.Add(-d.Retention)); err != nil {
+				log.Printf("purge webhook deliveries: %v", err)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) emitQueued(ctx context.Context) {
+	for {
+		select {
+		case e := <-d.events:
+			if err := d.Emit(ctx, e.userID, e.event, e.data); err != nil {
+				log.Printf("emit webhook event %s: %v", e.event, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DeliverDue claims deliveries due now and attempts each of them once.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	due, err := d.storage.ClaimDueDeliveries(ctx, time.Now(), d.Lease, d.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	queue := make(chan storage.Delivery)
+	var wg sync.WaitGroup
+	for i := 0; i < d.Concurrency && i < len(due); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range queue {
+				d.deliver(ctx, delivery)
+			}
+		}()
+	}
+	for _, delivery := range due {
+		select {
+		case queue <- delivery:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery storage.Delivery) {
+	hook, err := d.storage.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		log.Printf("get webhook of delivery %s: %v", delivery.ID, err)
+		return
+	}
+	if err := d.storage.SaveDelivery(ctx, d.attempt(ctx, hook, delivery)); err != nil {
+		log.Printf("save webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, hook storage.Webhook, delivery storage.Delivery) storage.Delivery {
+	now := time.Now()
+	status, err := d.send(ctx, hook, delivery, now)
+	delivery.Attempts++
+	delivery.LastStatus = status
+	delivery.LastError = ""
+
+	if err == nil {
+		delivery.Status = storage.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	if len(delivery.LastError) > maxErrorLength {
+		delivery.LastError = delivery.LastError[:maxErrorLength]
+	}
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = storage.DeliveryDead
+	} else {
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	return delivery
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.Backoff
+	for i := 1; i < attempts && backoff < d.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.MaxBackoff {
+		backoff = d.MaxBackoff
+	}
+
+	return backoff
+}
+
+func (d *Dispatcher) send(ctx context.Context, hook storage.Webhook, delivery storage.Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-shortener-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, []byte(delivery.Payload)))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns the signature header value of body sent at timestamp,
+// receivers recompute it over "timestamp.body" with the webhook secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,148 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/virp/go-shortener/internal/app/storage"
+)
+
+func TestDispatcher_DeliverDue(t *testing.T) {
+	var mu sync.Mutex
+	failures := map[string]int{"/flaky": 1, "/down": 100}
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, Sign("secret", r.Header.Get(TimestampHeader), body), r.Header.Get(SignatureHeader))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures[r.URL.Path] > 0 {
+			failures[r.URL.Path]--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, r.Header.Get(EventHeader))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s, err := storage.NewMemoryStorage()
+	require.NoError(t, err)
+	flaky, err := s.CreateWebhook(ctx, storage.Webhook{ID: "flaky", UserID: "user", URL: srv.URL + "/flaky", Secret: "secret", Events: storage.WebhookEvents{EventLinkCreated}})
+	require.NoError(t, err)
+	down, err := s.CreateWebhook(ctx, storage.Webhook{ID: "down", UserID: "user", URL: srv.URL + "/down", Secret: "secret"})
+	require.NoError(t, err)
+
+	d := NewDispatcher(s, srv.Client())
+	d.MaxAttempts = 2
+	d.Backoff = 0
+	require.NoError(t, d.Emit(ctx, "user", EventLinkCreated, map[string]string{"id": "1"}))
+	require.NoError(t, d.Emit(ctx, "user", EventLinkClicked, map[string]string{"id": "1"}))
+	require.NoError(t, d.Emit(ctx, "other", EventLinkCreated, map[string]string{"id": "2"}))
+
+	require.NoError(t, d.DeliverDue(ctx))
+	pending, err := s.FindDeliveries(ctx, flaky.ID, storage.DeliveryPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, pending[0].LastStatus)
+
+	require.NoError(t, d.DeliverDue(ctx))
+	delivered, err := s.FindDeliveries(ctx, flaky.ID, storage.DeliveryDelivered)
+	require.NoError(t, err)
+	require.Len(t, delivered, 1)
+	assert.Equal(t, 2, delivered[0].Attempts)
+	assert.NotNil(t, delivered[0].DeliveredAt)
+
+	dead, err := s.FindDeliveries(ctx, down.ID, storage.DeliveryDead)
+	require.NoError(t, err)
+	assert.Len(t, dead, 2)
+
+	assert.Equal(t, []string{EventLinkCreated}, received)
+}
+
+func TestDispatcher_DeliverDueConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.Header.Get(DeliveryHeader)]++
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s, err := storage.NewMemoryStorage()
+	require.NoError(t, err)
+	_, err = s.CreateWebhook(ctx, storage.Webhook{ID: "hook", UserID: "user", URL: srv.URL, Secret: "secret"})
+	require.NoError(t, err)
+	emitter := NewDispatcher(s, srv.Client())
+	for i := 0; i < 20; i++ {
+		require.NoError(t, emitter.Emit(ctx, "user", EventLinkClicked, map[string]int{"n": i}))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		d := NewDispatcher(s, srv.Client())
+		d.BatchSize = 10
+		d.Concurrency = 4
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, d.DeliverDue(ctx))
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, emitter.DeliverDue(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, received, 20)
+	for id, n := range received {
+		assert.Equal(t, 1, n, id)
+	}
+}
+
+func TestDispatcher_Enqueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := storage.NewMemoryStorage()
+	require.NoError(t, err)
+	hook, err := s.CreateWebhook(ctx, storage.Webhook{ID: "hook", UserID: "user", URL: "https://crm.example.org/hook", Secret: "secret"})
+	require.NoError(t, err)
+
+	d := NewDispatcher(s, http.DefaultClient)
+	assert.True(t, d.Enqueue("user", EventLinkClicked, map[string]string{"id": "1"}))
+	assert.True(t, d.Enqueue("", EventLinkClicked, map[string]string{"id": "2"}))
+	pending, err := s.FindDeliveries(ctx, hook.ID, storage.DeliveryPending)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	go d.emitQueued(ctx)
+	assert.Eventually(t, func() bool {
+		pending, err := s.FindDeliveries(ctx, hook.ID, storage.DeliveryPending)
+		return err == nil && len(pending) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(nil, nil)
+	d.Backoff = 30 * time.Second
+	d.MaxBackoff = 2 * time.Minute
+
+	for attempts, want := range []time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 4: 2 * time.Minute} {
+		if attempts == 0 {
+			continue
+		}
+		assert.Equal(t, want, d.backoff(attempts), "attempts %d", attempts)
+	}
+}